@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/zpeters/dropsearch/raindropio"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// collectionCache controls reuse of the collections list between runs
+type collectionCache struct {
+	// Path is the cache file, an empty path disables caching
+	Path string
+	// TTL is how long a cached list is used before fetching it again
+	TTL time.Duration
+	// Refresh forces the list to be fetched and the cache rewritten
+	Refresh bool
+}
+
+// collectionCachePath returns the cache file for an index, each index keeps
+// its own cache since they can hold different accounts
+func collectionCachePath(id indexID) (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "collections-"+id.fileKey()+".json"), nil
+}
+
+// cacheIsFresh reports whether a file modified at modTime is younger than ttl
+func cacheIsFresh(modTime time.Time, ttl time.Duration, now time.Time) bool {
+	return now.Sub(modTime) < ttl
+}
+
+// getCachedCollections returns the cached collections list while it is
+// fresh, otherwise fetches it and updates the cache
+func getCachedCollections(ctx context.Context, raindropClient *raindropio.Client, cache collectionCache) ([]raindropio.Collection, error) {
+	if cache.Path == "" {
+		return raindropClient.Collections(ctx)
+	}
+
+	if !cache.Refresh {
+		collections, ok := readCollectionCache(cache)
+		if ok {
+			return collections, nil
+		}
+	}
+
+	collections, err := raindropClient.Collections(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeCollectionCache(cache.Path, collections)
+	if err != nil {
+		return nil, err
+	}
+
+	return collections, nil
+}
+
+// readCollectionCache returns the cached collections if the cache exists, is
+// fresh and can be read
+func readCollectionCache(cache collectionCache) ([]raindropio.Collection, bool) {
+	info, err := os.Stat(cache.Path)
+	if err != nil || !cacheIsFresh(info.ModTime(), cache.TTL, time.Now()) {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(cache.Path)
+	if err != nil {
+		return nil, false
+	}
+
+	var collections []raindropio.Collection
+	err = json.Unmarshal(data, &collections)
+	if err != nil {
+		return nil, false
+	}
+
+	return collections, true
+}
+
+func writeCollectionCache(path string, collections []raindropio.Collection) error {
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return fmt.Errorf("error creating config dir: %w", err)
+	}
+
+	data, err := json.Marshal(collections)
+	if err != nil {
+		return fmt.Errorf("error marshalling collections cache: %w", err)
+	}
+
+	err = os.WriteFile(path, data, 0o644)
+	if err != nil {
+		return fmt.Errorf("error writing collections cache: %w", err)
+	}
+
+	return nil
+}
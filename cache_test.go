@@ -0,0 +1,50 @@
+package main
+
+import (
+	"github.com/zpeters/dropsearch/raindropio"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheIsFresh(t *testing.T) {
+	now := time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		age  time.Duration
+		want bool
+	}{
+		{0, true},
+		{59 * time.Minute, true},
+		{time.Hour, false},
+		{2 * time.Hour, false},
+	}
+	for _, test := range tests {
+		if got := cacheIsFresh(now.Add(-test.age), time.Hour, now); got != test.want {
+			t.Errorf("cacheIsFresh of a %s old file = %t, want %t", test.age, got, test.want)
+		}
+	}
+}
+
+func TestReadCollectionCacheUsesModTime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "collections.json")
+	err := writeCollectionCache(path, []raindropio.Collection{{ID: 1, Title: "Dev"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := collectionCache{Path: path, TTL: time.Hour}
+
+	collections, ok := readCollectionCache(cache)
+	if !ok || len(collections) != 1 || collections[0].Title != "Dev" {
+		t.Errorf("got %+v, %t from a fresh cache, want the cached collection", collections, ok)
+	}
+
+	stale := time.Now().Add(-2 * time.Hour)
+	err = os.Chtimes(path, stale, stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := readCollectionCache(cache); ok {
+		t.Error("a cache older than the TTL was used")
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// completionShells are the shells -completion writes scripts for
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// writeCompletion writes a completion script for shell covering every flag,
+// the -format names and, through -suggest-tags, the tags for -tag
+func writeCompletion(w io.Writer, shell string, flags *flag.FlagSet) error {
+	switch shell {
+	case "bash":
+		writeBashCompletion(w, flags)
+	case "zsh":
+		fmt.Fprintln(w, "#compdef dropsearch")
+		fmt.Fprintln(w, "# load with: source <(dropsearch -completion zsh)")
+		fmt.Fprintln(w, "autoload -U +X bashcompinit && bashcompinit")
+		writeBashCompletion(w, flags)
+	case "fish":
+		writeFishCompletion(w, flags)
+	default:
+		return fmt.Errorf("unknown shell %q, use one of %s", shell, strings.Join(completionShells, ", "))
+	}
+	return nil
+}
+
+func writeBashCompletion(w io.Writer, flags *flag.FlagSet) {
+	var names []string
+	flags.VisitAll(func(f *flag.Flag) {
+		names = append(names, "-"+f.Name)
+	})
+
+	fmt.Fprintln(w, "# bash completion for dropsearch, load with: source <(dropsearch -completion bash)")
+	fmt.Fprintln(w, "_dropsearch() {")
+	fmt.Fprintln(w, `	local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"`)
+	fmt.Fprintln(w, `	case "$prev" in`)
+	fmt.Fprintln(w, "	-tag)")
+	fmt.Fprintln(w, `		[ -n "$cur" ] && COMPREPLY=($(dropsearch -suggest-tags "$cur" 2>/dev/null))`)
+	fmt.Fprintln(w, "		return")
+	fmt.Fprintln(w, "		;;")
+	fmt.Fprintln(w, "	-format)")
+	fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(rendererNames(), " "))
+	fmt.Fprintln(w, "		return")
+	fmt.Fprintln(w, "		;;")
+	fmt.Fprintln(w, "	esac")
+	fmt.Fprintln(w, `	if [[ "$cur" == -* ]]; then`)
+	fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(names, " "))
+	fmt.Fprintln(w, "	fi")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "complete -o default -F _dropsearch dropsearch")
+}
+
+func writeFishCompletion(w io.Writer, flags *flag.FlagSet) {
+	fmt.Fprintln(w, "# fish completion for dropsearch, load with: dropsearch -completion fish | source")
+	flags.VisitAll(func(f *flag.Flag) {
+		description := strings.ReplaceAll(f.Usage, "'", `\'`)
+		switch {
+		case f.Name == "tag":
+			fmt.Fprintf(w, "complete -c dropsearch -o %s -x -a '(set -l t (commandline -ct); test -n \"$t\"; and dropsearch -suggest-tags $t 2>/dev/null)' -d '%s'\n", f.Name, description)
+		case f.Name == "format":
+			fmt.Fprintf(w, "complete -c dropsearch -o %s -x -a '%s' -d '%s'\n", f.Name, strings.Join(rendererNames(), " "), description)
+		case isBoolFlag(f):
+			fmt.Fprintf(w, "complete -c dropsearch -o %s -d '%s'\n", f.Name, description)
+		default:
+			fmt.Fprintf(w, "complete -c dropsearch -o %s -r -d '%s'\n", f.Name, description)
+		}
+	})
+}
+
+// isBoolFlag reports whether the flag is a switch that takes no value
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
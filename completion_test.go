@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBashCompletionListsFlags(t *testing.T) {
+	isolate(t)
+	code, stdout, stderr := runWith("-completion", "bash")
+	if code != exitOK {
+		t.Fatalf("exited %d: %s", code, stderr)
+	}
+	offered := map[string]bool{}
+	for _, line := range strings.Split(stdout, "\n") {
+		if _, words, ok := strings.Cut(line, `compgen -W "-`); ok {
+			words, _, _ = strings.Cut(words, `"`)
+			for _, word := range strings.Fields("-" + words) {
+				offered[word] = true
+			}
+		}
+	}
+
+	// the usage lists every registered flag
+	_, _, usage := runWith()
+	var listed int
+	for _, line := range strings.Split(usage, "\n") {
+		if !strings.HasPrefix(line, "  -") {
+			continue
+		}
+		listed++
+		if name := strings.Fields(line)[0]; !offered[name] {
+			t.Errorf("completion doesn't offer %s", name)
+		}
+	}
+	if listed == 0 || listed != len(offered) {
+		t.Errorf("completion offers %d flags, usage lists %d", len(offered), listed)
+	}
+	for _, name := range []string{"-i", "-tag", "-format", "-completion", "-suggest-tags"} {
+		if !offered[name] {
+			t.Errorf("completion doesn't offer %s", name)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// defaultHost is the meilisearch host used when none is configured
+const defaultHost = "http://search"
+
+// defaultIndex is the meilisearch index used when none is configured
+const defaultIndex = "raindrops"
+
+// Config holds the settings read from the config file, the environment and
+// flags, later sources overriding earlier ones
+type Config struct {
+	RaindropToken string `json:"raindropToken"`
+	SearchToken   string `json:"searchToken"`
+	Host          string `json:"host"`
+	Index         string `json:"index"`
+}
+
+// configFile is the layout of config.json, top level settings apply to every
+// profile and a profile's settings override them
+type configFile struct {
+	Config
+	DefaultProfile string            `json:"defaultProfile"`
+	Profiles       map[string]Config `json:"profiles"`
+}
+
+// merge overrides the fields of c with the non-empty fields of other
+func (c *Config) merge(other Config) {
+	if other.RaindropToken != "" {
+		c.RaindropToken = other.RaindropToken
+	}
+	if other.SearchToken != "" {
+		c.SearchToken = other.SearchToken
+	}
+	if other.Host != "" {
+		c.Host = other.Host
+	}
+	if other.Index != "" {
+		c.Index = other.Index
+	}
+}
+
+func configFilePath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.json"), nil
+}
+
+// readConfigFile reads the config file, returning an empty config if it
+// doesn't exist
+func readConfigFile(path string) (configFile, error) {
+	var config configFile
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return config, fmt.Errorf("error reading config file: %w", err)
+	}
+
+	err = json.Unmarshal(data, &config)
+	if err != nil {
+		return config, fmt.Errorf("error unmarshalling config file %s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// profile returns the settings of the named profile merged over the top
+// level settings. Without a name the file's defaultProfile is used, and
+// without either just the top level settings
+func (f configFile) profile(name string) (Config, error) {
+	if name == "" {
+		name = f.DefaultProfile
+	}
+
+	config := f.Config
+	if name == "" {
+		return config, nil
+	}
+
+	profile, ok := f.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(f.Profiles))
+		for known := range f.Profiles {
+			names = append(names, known)
+		}
+		sort.Strings(names)
+		return config, fmt.Errorf("unknown profile %q, the config file has %s", name, strings.Join(names, ", "))
+	}
+	config.merge(profile)
+	return config, nil
+}
+
+// envConfig reads the config from DROPSEARCH_* environment variables
+func envConfig() Config {
+	return Config{
+		RaindropToken: os.Getenv("DROPSEARCH_RAINDROP_TOKEN"),
+		SearchToken:   os.Getenv("DROPSEARCH_MEILISEARCH_TOKEN"),
+		Host:          os.Getenv("DROPSEARCH_MEILISEARCH_HOST"),
+		Index:         os.Getenv("DROPSEARCH_INDEX"),
+	}
+}
+
+// loadConfig builds the config from defaults, the config file's profile, the
+// environment and finally flags, each overriding the previous. An empty
+// profile falls back to DROPSEARCH_PROFILE and then the file's default
+func loadConfig(flags Config, profile string) (Config, error) {
+	config := Config{Host: defaultHost, Index: defaultIndex}
+
+	// without a config directory, like with HOME unset in a container, there
+	// is no config file to read and the environment and flags are enough
+	var file configFile
+	path, err := configFilePath()
+	if err != nil {
+		slog.Debug("no config directory, skipping the config file", "error", err)
+	} else {
+		file, err = readConfigFile(path)
+		if err != nil {
+			return config, err
+		}
+	}
+
+	if profile == "" {
+		profile = os.Getenv("DROPSEARCH_PROFILE")
+	}
+	fileConfig, err := file.profile(profile)
+	if err != nil {
+		return config, err
+	}
+
+	config.merge(fileConfig)
+	config.merge(envConfig())
+	config.merge(flags)
+
+	return config, nil
+}
+
+// profileConfigs returns the config of every profile in the config file,
+// sorted by name, for searching all of their indexes at once. Flags and the
+// environment aren't applied since they would point every profile at the same
+// index, except that tokens missing from a profile fall back to base
+func profileConfigs(base Config) ([]string, []Config, error) {
+	path, err := configFilePath()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := readConfigFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(file.Profiles) == 0 {
+		return nil, nil, fmt.Errorf("%s has no profiles to search", path)
+	}
+
+	names := make([]string, 0, len(file.Profiles))
+	for name := range file.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	configs := make([]Config, 0, len(names))
+	for _, name := range names {
+		profile, err := file.profile(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		config := Config{
+			RaindropToken: base.RaindropToken,
+			SearchToken:   base.SearchToken,
+			Host:          defaultHost,
+			Index:         defaultIndex,
+		}
+		config.merge(profile)
+		configs = append(configs, config)
+	}
+
+	return names, configs, nil
+}
+
+// readTokenFile reads a token kept in its own file, like a Docker or
+// Kubernetes secret, trimming the surrounding whitespace
+func readTokenFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading token file: %w", err)
+	}
+
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", path)
+	}
+	return token, nil
+}
+
+// readTokenFiles sets the tokens that weren't given as flags from the token
+// files, so they override the environment and config file but not flags.
+// Empty paths are skipped
+func (c *Config) readTokenFiles(raindropPath string, searchPath string) error {
+	if raindropPath != "" && c.RaindropToken == "" {
+		token, err := readTokenFile(raindropPath)
+		if err != nil {
+			return err
+		}
+		c.RaindropToken = token
+	}
+	if searchPath != "" && c.SearchToken == "" {
+		token, err := readTokenFile(searchPath)
+		if err != nil {
+			return err
+		}
+		c.SearchToken = token
+	}
+	return nil
+}
+
+// errMissingRaindropToken and errMissingSearchToken explain which setting is
+// missing for the requested operation
+var (
+	errMissingRaindropToken = errors.New("missing Raindrop token, set DROPSEARCH_RAINDROP_TOKEN or raindropToken in the config file")
+	errMissingSearchToken   = errors.New("missing Meilisearch token, set DROPSEARCH_MEILISEARCH_TOKEN or searchToken in the config file")
+)
+
+// validateTokens checks the tokens needed by an operation are set. Every
+// operation needs the token of each service it talks to, so indexing needs
+// both while searching only needs the Meilisearch token
+func validateTokens(config Config, needRaindrop bool, needSearch bool) error {
+	if needRaindrop && config.RaindropToken == "" {
+		return errMissingRaindropToken
+	}
+	if needSearch && config.SearchToken == "" {
+		return errMissingSearchToken
+	}
+	return nil
+}
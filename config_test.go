@@ -0,0 +1,191 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes the config file of an isolated test
+func writeConfig(t *testing.T, content string) {
+	t.Helper()
+	path, err := configFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(path, []byte(content), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	isolate(t)
+	writeConfig(t, `{"raindropToken": "file-raindrop", "searchToken": "file-search", "host": "http://file:7700", "index": "file-index"}`)
+	t.Setenv("DROPSEARCH_MEILISEARCH_TOKEN", "env-search")
+	t.Setenv("DROPSEARCH_MEILISEARCH_HOST", "http://env:7700")
+
+	config, err := loadConfig(Config{Host: "http://flag:7700"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Config{
+		RaindropToken: "file-raindrop",
+		SearchToken:   "env-search",
+		Host:          "http://flag:7700",
+		Index:         "file-index",
+	}
+	if config != want {
+		t.Errorf("got %+v, want %+v", config, want)
+	}
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	isolate(t)
+
+	config, err := loadConfig(Config{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config != (Config{Host: defaultHost, Index: defaultIndex}) {
+		t.Errorf("got %+v without a config file, want only the defaults", config)
+	}
+}
+
+func TestLoadConfigWithoutConfigDir(t *testing.T) {
+	isolate(t)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("HOME", "")
+	t.Setenv("DROPSEARCH_RAINDROP_TOKEN", "env-raindrop")
+
+	config, err := loadConfig(Config{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.RaindropToken != "env-raindrop" {
+		t.Errorf("got %+v, want the environment to still apply", config)
+	}
+}
+
+func TestValidateTokens(t *testing.T) {
+	both := Config{RaindropToken: "raindrop", SearchToken: "search"}
+	tests := []struct {
+		name         string
+		config       Config
+		needRaindrop bool
+		needSearch   bool
+		want         error
+	}{
+		{"index with both", both, true, true, nil},
+		{"index without search token", Config{RaindropToken: "raindrop"}, true, true, errMissingSearchToken},
+		{"index without raindrop token", Config{SearchToken: "search"}, true, true, errMissingRaindropToken},
+		{"search without raindrop token", Config{SearchToken: "search"}, false, true, nil},
+		{"search without search token", Config{RaindropToken: "raindrop"}, false, true, errMissingSearchToken},
+		{"tags without search token", Config{RaindropToken: "raindrop"}, true, false, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := validateTokens(test.config, test.needRaindrop, test.needSearch); err != test.want {
+				t.Errorf("got %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestRunIndexNeedsBothTokens(t *testing.T) {
+	isolate(t)
+	t.Setenv("DROPSEARCH_RAINDROP_TOKEN", "raindrop")
+
+	code, _, stderr := runWith("-i")
+	if code != exitError || !strings.Contains(stderr, "DROPSEARCH_MEILISEARCH_TOKEN") {
+		t.Errorf("-i without a Meilisearch token exited %d with %q, want an error naming it", code, stderr)
+	}
+}
+
+func TestLoadConfigProfile(t *testing.T) {
+	isolate(t)
+	writeConfig(t, `{
+		"searchToken": "shared-search",
+		"host": "http://shared:7700",
+		"defaultProfile": "home",
+		"profiles": {
+			"home": {"index": "home-bookmarks"},
+			"work": {"host": "http://work:7700", "index": "work-bookmarks", "searchToken": "work-search"}
+		}
+	}`)
+
+	tests := []struct {
+		name    string
+		profile string
+		env     string
+		want    Config
+	}{
+		{"named profile", "work", "", Config{SearchToken: "work-search", Host: "http://work:7700", Index: "work-bookmarks"}},
+		{"default profile", "", "", Config{SearchToken: "shared-search", Host: "http://shared:7700", Index: "home-bookmarks"}},
+		{"profile from the environment", "", "work", Config{SearchToken: "work-search", Host: "http://work:7700", Index: "work-bookmarks"}},
+		{"flag over the environment", "home", "work", Config{SearchToken: "shared-search", Host: "http://shared:7700", Index: "home-bookmarks"}},
+	}
+	for _, test := range tests {
+		t.Setenv("DROPSEARCH_PROFILE", test.env)
+		config, err := loadConfig(Config{}, test.profile)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if config != test.want {
+			t.Errorf("%s: got %+v, want %+v", test.name, config, test.want)
+		}
+	}
+
+	t.Setenv("DROPSEARCH_PROFILE", "")
+	_, err := loadConfig(Config{}, "play")
+	if err == nil || !strings.Contains(err.Error(), `unknown profile "play", the config file has home, work`) {
+		t.Errorf("unknown profile returned %v, want the known profiles listed", err)
+	}
+}
+
+func TestReadTokenFiles(t *testing.T) {
+	isolate(t)
+	dir := t.TempDir()
+	raindropPath := filepath.Join(dir, "raindrop-token")
+	searchPath := filepath.Join(dir, "search-token")
+	for path, content := range map[string]string{raindropPath: "  file-raindrop\n", searchPath: "file-search\n"} {
+		err := os.WriteFile(path, []byte(content), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("DROPSEARCH_RAINDROP_TOKEN", "env-raindrop")
+	t.Setenv("DROPSEARCH_MEILISEARCH_TOKEN", "env-search")
+
+	// a token given as a flag wins over its file, a file over the environment
+	flags := Config{SearchToken: "flag-search"}
+	err := flags.readTokenFiles(raindropPath, searchPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig(flags, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.RaindropToken != "file-raindrop" || config.SearchToken != "flag-search" {
+		t.Errorf("got tokens %q and %q, want the trimmed file token and the flag", config.RaindropToken, config.SearchToken)
+	}
+
+	empty := filepath.Join(dir, "empty")
+	err = os.WriteFile(empty, []byte("\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{empty, filepath.Join(dir, "missing")} {
+		var config Config
+		if err := config.readTokenFiles(path, ""); err == nil {
+			t.Errorf("reading %s succeeded, want an error", filepath.Base(path))
+		}
+	}
+}
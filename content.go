@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"github.com/zpeters/dropsearch/raindropio"
+	"html"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cachedPageTimeout is how long a single cached page may take to download,
+// so one slow page can't use up the whole -timeout
+const cachedPageTimeout = 30 * time.Second
+
+// maxContentLength caps the page text stored per bookmark, meilisearch only
+// indexes the first words of long fields anyway
+const maxContentLength = 100000
+
+// htmlInvisible matches elements whose text is never shown on the page
+var htmlInvisible = regexp.MustCompile(`(?is)<(script|style|noscript|template|svg)\b.*?</(script|style|noscript|template|svg)\s*>|<!--.*?-->`)
+
+// htmlTag matches any tag
+var htmlTag = regexp.MustCompile(`(?s)<[^>]*>`)
+
+// htmlToText strips the markup from an HTML page, leaving its visible text
+// with whitespace collapsed to single spaces
+func htmlToText(page string) string {
+	page = htmlInvisible.ReplaceAllString(page, " ")
+	page = htmlTag.ReplaceAllString(page, " ")
+	text := strings.Join(strings.Fields(html.UnescapeString(page)), " ")
+	if len(text) > maxContentLength {
+		text = strings.ToValidUTF8(text[:maxContentLength], "")
+	}
+	return text
+}
+
+// fetchCachedContent fills in Content for every raindrop with a ready cached
+// copy using up to concurrency workers. Pages that fail to download are
+// logged and left without content rather than failing the run
+func fetchCachedContent(ctx context.Context, raindropClient *raindropio.Client, raindrops []raindropio.Raindrop, concurrency int) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				pageCtx, cancel := context.WithTimeout(ctx, cachedPageTimeout)
+				page, err := raindropClient.CachedPage(pageCtx, raindrops[i].ID)
+				cancel()
+				if err != nil {
+					slog.Warn("error fetching cached page", "id", raindrops[i].ID, "error", err)
+					continue
+				}
+				raindrops[i].Content = htmlToText(string(page))
+			}
+		}()
+	}
+
+feed:
+	for i := range raindrops {
+		if raindrops[i].Cache.Status != "ready" {
+			continue
+		}
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/zpeters/dropsearch/raindropio"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestHTMLToText(t *testing.T) {
+	tests := []struct {
+		page string
+		want string
+	}{
+		{"<p>Hello <b>Go</b></p>", "Hello Go"},
+		{"<html><head><title>Go</title><style>p { color: red }</style></head><body>\n\n  <p>Fast &amp; simple</p></body></html>", "Go Fast & simple"},
+		{"<script>alert('hi')</script><p>visible</p><!-- hidden -->", "visible"},
+		{"<SCRIPT type=\"text/javascript\">var x = 1 < 2;</SCRIPT>text", "text"},
+		{"<svg><text>logo</text></svg><noscript>enable js</noscript>page", "page"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if got := htmlToText(test.page); got != test.want {
+			t.Errorf("htmlToText(%q) = %q, want %q", test.page, got, test.want)
+		}
+	}
+
+	long := htmlToText("<p>" + strings.Repeat("é", maxContentLength) + "</p>")
+	if len(long) > maxContentLength || !strings.HasPrefix(long, "éé") || strings.ToValidUTF8(long, "") != long {
+		t.Errorf("long page gave %d bytes, want at most %d of valid UTF-8", len(long), maxContentLength)
+	}
+}
+
+func TestFetchCachedContent(t *testing.T) {
+	client := newRaindropClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/v1/raindrop/1/cache":
+			fmt.Fprint(w, "<p>Effective Go</p>")
+		default:
+			t.Errorf("downloaded %s, want only ready pages", r.URL.Path)
+		}
+	})
+
+	raindrops := make([]raindropio.Raindrop, 2)
+	raindrops[0].ID = 1
+	raindrops[0].Cache.Status = "ready"
+	raindrops[1].ID = 2
+	raindrops[1].Cache.Status = "failed"
+
+	fetchCachedContent(context.Background(), client, raindrops, 2)
+	if raindrops[0].Content != "Effective Go" || raindrops[1].Content != "" {
+		t.Errorf("content = %q and %q, want only the ready page's text", raindrops[0].Content, raindrops[1].Content)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+)
+
+// Exit codes, so scripts can tell a search without hits from a failure
+const (
+	exitOK     = 0
+	exitError  = 1
+	exitUsage  = 2
+	exitNoHits = 3
+)
+
+// errNoHits is returned when a search worked but matched nothing
+var errNoHits = errors.New("no hits")
+
+// errUsageShown is returned for flags the flag package already reported,
+// along with the usage, so nothing more needs printing
+var errUsageShown = errors.New("invalid flags")
+
+// usageError is an error caused by invalid flags or arguments rather than by
+// something going wrong while running
+type usageError struct {
+	err error
+}
+
+func (e usageError) Error() string {
+	return e.err.Error()
+}
+
+func (e usageError) Unwrap() error {
+	return e.err
+}
+
+// usageErrorf formats a usageError, wrapping any %w argument
+func usageErrorf(format string, a ...any) error {
+	return usageError{err: fmt.Errorf(format, a...)}
+}
+
+// exitCode maps the error returned by run to the process exit code
+func exitCode(err error) int {
+	var usageErr usageError
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.Is(err, errNoHits):
+		return exitNoHits
+	case errors.Is(err, errUsageShown):
+		return exitUsage
+	case errors.As(err, &usageErr):
+		return exitUsage
+	default:
+		return exitError
+	}
+}
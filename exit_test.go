@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, exitOK},
+		{flag.ErrHelp, exitOK},
+		{errNoHits, exitNoHits},
+		{fmt.Errorf("searching: %w", errNoHits), exitNoHits},
+		{errUsageShown, exitUsage},
+		{usageErrorf("-limit must be positive"), exitUsage},
+		{fmt.Errorf("wrapped: %w", usageErrorf("bad flag")), exitUsage},
+		{errors.New("meilisearch is down"), exitError},
+	}
+	for _, test := range tests {
+		if got := exitCode(test.err); got != test.want {
+			t.Errorf("exitCode(%v) = %d, want %d", test.err, got, test.want)
+		}
+	}
+}
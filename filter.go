@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sortKeys maps the sort keys accepted by -sort to the indexed field
+var sortKeys = map[string]string{
+	"created": "created_unix",
+}
+
+// parseSort turns a sort flag like "created:desc" into the meilisearch sort
+// rules, an empty value means relevance order
+func parseSort(value string) ([]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	key, direction, found := strings.Cut(value, ":")
+	if !found {
+		direction = "asc"
+	}
+
+	field, ok := sortKeys[key]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort key %q, use created", key)
+	}
+	if direction != "asc" && direction != "desc" {
+		return nil, fmt.Errorf("unknown sort direction %q, use asc or desc", direction)
+	}
+
+	return []string{field + ":" + direction}, nil
+}
+
+// matchingStrategies are the values accepted by -match, last drops words from
+// the end of the query until something matches, all requires every word
+var matchingStrategies = []string{"last", "all"}
+
+// parseMatchingStrategy checks a -match value, an empty value leaves the
+// choice to meilisearch
+func parseMatchingStrategy(value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return "", nil
+	}
+	for _, strategy := range matchingStrategies {
+		if value == strategy {
+			return value, nil
+		}
+	}
+	return "", fmt.Errorf("unknown matching strategy %q, use %s", value, strings.Join(matchingStrategies, " or "))
+}
+
+// stringList is a flag.Value collecting every use of a repeatable flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// limitValue is a flag.Value for -limit that also accepts "all", stored as 0
+type limitValue int
+
+func (l *limitValue) String() string {
+	return strconv.Itoa(int(*l))
+}
+
+func (l *limitValue) Set(value string) error {
+	if strings.EqualFold(value, "all") {
+		*l = 0
+		return nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%q is not a number or all", value)
+	}
+	*l = limitValue(i)
+	return nil
+}
+
+// intList is a flag.Value collecting every use of a repeatable integer flag
+type intList []int
+
+func (l *intList) String() string {
+	values := make([]string, 0, len(*l))
+	for _, value := range *l {
+		values = append(values, strconv.Itoa(value))
+	}
+	return strings.Join(values, ",")
+}
+
+func (l *intList) Set(value string) error {
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%q is not a number", value)
+	}
+	*l = append(*l, i)
+	return nil
+}
+
+// parseTagMatch reads the -tag-match mode, reporting whether any tag is
+// enough for a bookmark to match
+func parseTagMatch(value string) (bool, error) {
+	switch value {
+	case "all":
+		return false, nil
+	case "any":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown tag match %q, use all or any", value)
+	}
+}
+
+// dateLayout is the short date format accepted by the date flags
+const dateLayout = "2006-01-02"
+
+// parseDate parses an RFC3339 timestamp or a YYYY-MM-DD date in local time.
+// With endOfDay set a plain date means the last second of that day, so a
+// -before date includes the whole day
+func parseDate(value string, endOfDay bool) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return t, nil
+	}
+
+	t, err = time.ParseInLocation(dateLayout, value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, use YYYY-MM-DD or RFC3339 like 2006-01-02T15:04:05Z", value)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Second)
+	}
+	return t, nil
+}
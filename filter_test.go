@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	start, err := parseDate("2024-03-05", false)
+	if err != nil || !start.Equal(time.Date(2024, 3, 5, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("parseDate = %s, %v, want the start of the day", start, err)
+	}
+	end, err := parseDate("2024-03-05", true)
+	if err != nil || !end.Equal(time.Date(2024, 3, 5, 23, 59, 59, 0, time.Local)) {
+		t.Errorf("parseDate with endOfDay = %s, %v, want the last second of the day", end, err)
+	}
+	exact, err := parseDate("2024-03-05T10:00:00Z", true)
+	if err != nil || !exact.Equal(time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("parseDate of a timestamp = %s, %v, want it unchanged", exact, err)
+	}
+	if _, err := parseDate("05/03/2024", false); err == nil {
+		t.Error("parseDate should reject other formats")
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"", "[]"},
+		{"created", "[created_unix:asc]"},
+		{"created:desc", "[created_unix:desc]"},
+	}
+	for _, test := range tests {
+		got, err := parseSort(test.value)
+		if err != nil || fmt.Sprint(got) != test.want {
+			t.Errorf("parseSort(%q) = %v, %v, want %s", test.value, got, err, test.want)
+		}
+	}
+	for _, value := range []string{"title", "created:up"} {
+		if _, err := parseSort(value); err == nil {
+			t.Errorf("parseSort(%q) should fail", value)
+		}
+	}
+}
+
+func TestParseTagMatch(t *testing.T) {
+	if any, err := parseTagMatch("any"); err != nil || !any {
+		t.Errorf("parseTagMatch(any) = %v, %v", any, err)
+	}
+	if any, err := parseTagMatch("all"); err != nil || any {
+		t.Errorf("parseTagMatch(all) = %v, %v", any, err)
+	}
+	if _, err := parseTagMatch("some"); err == nil {
+		t.Error("parseTagMatch(some) should fail")
+	}
+}
+
+func TestParseMatchingStrategy(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"", ""},
+		{"last", "last"},
+		{" ALL ", "all"},
+	}
+	for _, test := range tests {
+		if strategy, err := parseMatchingStrategy(test.value); err != nil || strategy != test.want {
+			t.Errorf("parseMatchingStrategy(%q) = %q, %v, want %q", test.value, strategy, err, test.want)
+		}
+	}
+	if _, err := parseMatchingStrategy("frequency"); err == nil {
+		t.Error("parseMatchingStrategy(frequency) should fail")
+	}
+}
+
+func TestLimitValue(t *testing.T) {
+	var limit limitValue
+	if err := limit.Set("all"); err != nil || limit != 0 {
+		t.Errorf("Set(all) = %d, %v, want 0", limit, err)
+	}
+	if err := limit.Set("25"); err != nil || limit != 25 {
+		t.Errorf("Set(25) = %d, %v, want 25", limit, err)
+	}
+	if err := limit.Set("lots"); err == nil {
+		t.Error("Set(lots) should fail")
+	}
+}
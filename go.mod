@@ -2,17 +2,20 @@ module github.com/zpeters/dropsearch
 
 go 1.21
 
+require (
+	github.com/briandowns/spinner v1.23.0
+	github.com/fatih/color v1.16.0
+	github.com/mattn/go-isatty v0.0.20
+	github.com/meilisearch/meilisearch-go v0.26.1
+)
+
 require (
 	github.com/andybalholm/brotli v1.0.4 // indirect
-	github.com/briandowns/spinner v1.23.0 // indirect
-	github.com/fatih/color v1.16.0 // indirect
 	github.com/golang-jwt/jwt/v4 v4.5.0 // indirect
 	github.com/josharian/intern v1.0.0 // indirect
 	github.com/klauspost/compress v1.15.6 // indirect
 	github.com/mailru/easyjson v0.7.7 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
-	github.com/mattn/go-isatty v0.0.20 // indirect
-	github.com/meilisearch/meilisearch-go v0.26.1 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
 	github.com/valyala/fasthttp v1.37.1-0.20220607072126-8a320890c08d // indirect
 	golang.org/x/sys v0.14.0 // indirect
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/fatih/color"
+	"github.com/meilisearch/meilisearch-go"
+	"github.com/zpeters/dropsearch/searchindex"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// defaultHighlightsIndex is the index highlights are kept in unless
+// -highlights-index says otherwise
+const defaultHighlightsIndex = "highlights"
+
+// unsupportedHighlightsOption names the first search option that only
+// applies to bookmarks, or returns "" when searchHighlights can honour them all
+func unsupportedHighlightsOption(opts searchOptions) string {
+	switch opts.Renderer.(type) {
+	case textRenderer, jsonRenderer:
+	default:
+		return "-format other than text or json"
+	}
+	switch {
+	case opts.Limit == 0:
+		return "-limit all"
+	case opts.Count:
+		return "-count"
+	case opts.Open > 0:
+		return "-open or -first"
+	case opts.Interactive:
+		return "-interactive"
+	case opts.RankingScore:
+		return "-explain"
+	case opts.MinScore > 0:
+		return "-min-score"
+	case len(opts.Sort) > 0:
+		return "-sort"
+	case opts.Filter() != "":
+		return "filters like -tag, -collection or -after"
+	}
+	return ""
+}
+
+// searchHighlights searches the highlights index, printing each highlight
+// with the bookmark it came from, or a JSON array with -format json
+func searchHighlights(w io.Writer, client *meilisearch.Client, indexName string, query string, opts searchOptions) error {
+	hits, estimatedTotal, err := searchindex.SearchHighlights(client.Index(indexName), query, opts.Query)
+	if searchindex.IsIndexNotFound(err) {
+		return fmt.Errorf("index %q doesn't exist yet, run dropsearch -i -index-highlights to index your highlights", indexName)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, ok := opts.Renderer.(jsonRenderer); ok {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(hits)
+		if err != nil {
+			return fmt.Errorf("error writing results: %w", err)
+		}
+	} else {
+		slog.Info(hitSummary(len(hits), estimatedTotal, query))
+		linkColor := color.New(color.FgBlue).SprintFunc()
+		infoColor := color.New(color.Faint).SprintFunc()
+		for i, hit := range hits {
+			fmt.Fprintf(w, "%d. %s\n", int64(i)+opts.Offset+1, strings.Join(strings.Fields(hit.Text), " "))
+			if hit.Note != "" {
+				fmt.Fprintf(w, "   Note: %s\n", hit.Note)
+			}
+			fmt.Fprintf(w, "   From: %s %s\n", infoColor(hit.Title), linkColor(hit.Link))
+			fmt.Fprintln(w)
+		}
+	}
+
+	if len(hits) == 0 {
+		return errNoHits
+	}
+	return nil
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/meilisearch/meilisearch-go"
+	"github.com/zpeters/dropsearch/raindropio"
+	"github.com/zpeters/dropsearch/searchindex"
+	"hash/fnv"
+	"html"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// readRaindropCSVFile reads the Raindrop CSV export at path
+func readRaindropCSVFile(path string) ([]raindropio.Raindrop, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening export: %w", err)
+	}
+	defer f.Close()
+
+	return readRaindropCSV(f)
+}
+
+// readRaindropCSV parses a Raindrop CSV export. Columns are found by their
+// header, so exports with extra or reordered columns still load, only title
+// and url are required
+func readRaindropCSV(r io.Reader) ([]raindropio.Raindrop, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading csv header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"title", "url"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("csv is missing the %q column", required)
+		}
+	}
+
+	var raindrops []raindropio.Raindrop
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading csv: %w", err)
+		}
+
+		field := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		raindrop, err := csvRaindrop(field)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		raindrops = append(raindrops, raindrop)
+	}
+
+	return raindrops, nil
+}
+
+// csvRaindrop builds a raindrop from the fields of one export row, filling in
+// the derived fields that indexing would normally add
+func csvRaindrop(field func(name string) string) (raindropio.Raindrop, error) {
+	raindrop := raindropio.Raindrop{
+		Title:   field("title"),
+		Link:    field("url"),
+		Note:    field("note"),
+		Excerpt: field("excerpt"),
+		Cover:   field("cover"),
+		Type:    "link",
+	}
+	if raindrop.Link == "" {
+		return raindropio.Raindrop{}, errors.New("missing url")
+	}
+
+	raindrop.ID = importedID(raindrop.Link)
+	if id := field("id"); id != "" {
+		n, err := strconv.Atoi(id)
+		if err != nil {
+			return raindropio.Raindrop{}, fmt.Errorf("invalid id %q", id)
+		}
+		raindrop.ID = n
+	}
+
+	if created := field("created"); created != "" {
+		t, err := time.Parse(time.RFC3339, created)
+		if err != nil {
+			return raindropio.Raindrop{}, fmt.Errorf("invalid created date %q", created)
+		}
+		raindrop.Created = t
+		raindrop.LastUpdate = t
+		raindrop.CreatedUnix = t.Unix()
+	}
+
+	raindrop.Tags = splitList(field("tags"))
+	raindrop.Domain = linkDomain(raindrop.Link)
+	raindrop.Important = field("favorite") == "true"
+
+	if folder := field("folder"); folder != "" {
+		raindrop.CollectionPath = folder
+		raindrop.CollectionTitle = folder[strings.LastIndex(folder, "/")+1:]
+	}
+
+	return raindrop, nil
+}
+
+// readBookmarksHTMLFile reads the Netscape bookmarks export at path
+func readBookmarksHTMLFile(path string) ([]raindropio.Raindrop, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading export: %w", err)
+	}
+
+	return readBookmarksHTML(string(data))
+}
+
+// bookmarksHTMLToken matches the parts of a Netscape bookmarks file that
+// matter, folder headings, links and the lists that nest them
+var bookmarksHTMLToken = regexp.MustCompile(`(?is)<h3[^>]*>(.*?)</h3>|<a\s([^>]*)>(.*?)</a>|<dl[^>]*>|</dl>`)
+
+// htmlAttribute matches a quoted attribute inside a tag
+var htmlAttribute = regexp.MustCompile(`(?s)([a-zA-Z_-]+)\s*=\s*"([^"]*)"`)
+
+// readBookmarksHTML parses the Netscape bookmark format browsers export.
+// Every <DL> list belongs to the <H3> folder heading before it, so folders
+// become the collection path and ADD_DATE the created date
+func readBookmarksHTML(doc string) ([]raindropio.Raindrop, error) {
+	var (
+		raindrops []raindropio.Raindrop
+		folders   []string
+		heading   string
+	)
+
+	for _, match := range bookmarksHTMLToken.FindAllStringSubmatch(doc, -1) {
+		token := strings.ToLower(match[0])
+		switch {
+		case strings.HasPrefix(token, "<h3"):
+			heading = strings.TrimSpace(html.UnescapeString(match[1]))
+		case strings.HasPrefix(token, "<dl"):
+			folders = append(folders, heading)
+			heading = ""
+		case token == "</dl>":
+			if len(folders) > 0 {
+				folders = folders[:len(folders)-1]
+			}
+		default:
+			raindrop, err := htmlRaindrop(match[2], match[3], folders)
+			if err != nil {
+				return nil, err
+			}
+			if raindrop.Link != "" {
+				raindrops = append(raindrops, raindrop)
+			}
+		}
+	}
+
+	return raindrops, nil
+}
+
+// htmlRaindrop builds a raindrop from the attributes and text of an <A> tag
+// inside the given folders
+func htmlRaindrop(attributes string, text string, folders []string) (raindropio.Raindrop, error) {
+	attrs := make(map[string]string)
+	for _, attr := range htmlAttribute.FindAllStringSubmatch(attributes, -1) {
+		attrs[strings.ToLower(attr[1])] = html.UnescapeString(attr[2])
+	}
+
+	raindrop := raindropio.Raindrop{
+		Title: strings.TrimSpace(html.UnescapeString(text)),
+		Link:  attrs["href"],
+		Type:  "link",
+	}
+	raindrop.ID = importedID(raindrop.Link)
+	raindrop.Domain = linkDomain(raindrop.Link)
+	raindrop.Tags = splitList(attrs["tags"])
+
+	for _, name := range []string{"add_date", "last_modified"} {
+		value := attrs[name]
+		if value == "" {
+			continue
+		}
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return raindropio.Raindrop{}, fmt.Errorf("invalid %s %q for %s", strings.ToUpper(name), value, raindrop.Link)
+		}
+		t := time.Unix(seconds, 0)
+		if name == "add_date" {
+			raindrop.Created = t
+			raindrop.CreatedUnix = seconds
+		}
+		raindrop.LastUpdate = t
+	}
+
+	var path []string
+	for _, folder := range folders {
+		if folder != "" {
+			path = append(path, folder)
+		}
+	}
+	if len(path) > 0 {
+		raindrop.CollectionTitle = path[len(path)-1]
+		raindrop.CollectionPath = strings.Join(path, "/")
+	}
+
+	return raindrop, nil
+}
+
+// importedID derives a stable id from the link for exports without ids, so
+// importing the same export again updates documents instead of adding more.
+// The ids are negative so they never collide with ids Raindrop hands out
+func importedID(link string) int {
+	h := fnv.New32a()
+	h.Write([]byte(link))
+	return -int(h.Sum32()&0x7fffffff) - 1
+}
+
+// linkDomain returns the host of link without a leading www., the same way
+// Raindrop fills in the domain
+func linkDomain(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// importBookmarks indexes raindrops that were read from an export instead of
+// the Raindrop API
+func importBookmarks(ctx context.Context, w io.Writer, client *meilisearch.Client, raindrops []raindropio.Raindrop, opts indexOptions) error {
+	slog.Info("import started", "index", opts.IndexName, "documents", len(raindrops))
+
+	index := client.Index(opts.IndexName)
+	if !opts.DryRun {
+		err := ensureIndex(ctx, client, opts.IndexName)
+		if err != nil {
+			return err
+		}
+		err = searchindex.Configure(index, opts.Settings)
+		if err != nil {
+			return err
+		}
+	}
+
+	dedupe := searchindex.NewDeduper()
+	raindrops = dedupe.Filter(raindrops)
+	searchindex.AddFavicons(raindrops, opts.FaviconService)
+	if opts.NormalizeTags {
+		for i := range raindrops {
+			raindrops[i].Tags = searchindex.NormalizeTags(raindrops[i].Tags)
+		}
+	}
+
+	sender := searchindex.NewSender(client, opts.IndexName, opts.BatchSize)
+	sender.DryRun = opts.DryRun
+	sender.Retries = opts.Retries
+	err := sender.Add(ctx, raindrops)
+	if err != nil {
+		return err
+	}
+	err = sender.Flush(ctx)
+	if err != nil {
+		return err
+	}
+
+	if opts.DryRun {
+		fmt.Fprintln(w, "dry run, nothing was sent to meilisearch")
+		fmt.Fprintf(w, "%d documents would be imported, %d duplicates\n", sender.Sent(), dedupe.Duplicates)
+		return nil
+	}
+
+	err = sender.Wait(ctx)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("documents imported", "index", opts.IndexName, "count", sender.Sent(), "duplicates", dedupe.Duplicates)
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadRaindropCSV(t *testing.T) {
+	export := `id,title,note,excerpt,url,folder,tags,created,cover,highlights,favorite
+42,Effective Go,,Writing clear Go,https://go.dev/doc/effective_go,Reading/Go,"go, docs",2024-03-01T10:00:00Z,,,true
+,"Go, the language",,,https://www.golang.org/,,,,,,false
+`
+	raindrops, err := readRaindropCSV(strings.NewReader(export))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(raindrops) != 2 {
+		t.Fatalf("got %d raindrops, want 2", len(raindrops))
+	}
+
+	first := raindrops[0]
+	if first.ID != 42 || first.Title != "Effective Go" || first.Excerpt != "Writing clear Go" {
+		t.Errorf("first row = %+v, want the id, title and excerpt from the export", first)
+	}
+	if first.CollectionPath != "Reading/Go" || first.CollectionTitle != "Go" {
+		t.Errorf("folder gave path %q and title %q, want Reading/Go and Go", first.CollectionPath, first.CollectionTitle)
+	}
+	if strings.Join(first.Tags, "|") != "go|docs" || !first.Important || first.Domain != "go.dev" {
+		t.Errorf("first row tags %v, important %v, domain %q", first.Tags, first.Important, first.Domain)
+	}
+	if want := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC); !first.Created.Equal(want) || first.CreatedUnix != want.Unix() {
+		t.Errorf("created = %v, want %v", first.Created, want)
+	}
+
+	second := raindrops[1]
+	if second.Title != "Go, the language" || second.Domain != "golang.org" {
+		t.Errorf("second row = %+v, want the quoted title and the domain without www.", second)
+	}
+	if second.ID != importedID("https://www.golang.org/") {
+		t.Errorf("row without an id got id %d, want the id derived from its link", second.ID)
+	}
+}
+
+func TestReadRaindropCSVErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		export string
+		want   string
+	}{
+		{"missing column", "title,note\nGo,\n", `missing the "url" column`},
+		{"missing url", "title,url\nGo,\n", "line 2: missing url"},
+		{"invalid id", "id,title,url\nabc,Go,https://go.dev\n", `line 2: invalid id "abc"`},
+		{"invalid date", "title,url,created\nGo,https://go.dev,yesterday\n", `line 2: invalid created date "yesterday"`},
+	}
+	for _, test := range tests {
+		_, err := readRaindropCSV(strings.NewReader(test.export))
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: readRaindropCSV() = %v, want an error containing %q", test.name, err, test.want)
+		}
+	}
+}
+
+func TestImportedID(t *testing.T) {
+	links := []string{"https://go.dev/", "https://example.com/", ""}
+	for _, link := range links {
+		id := importedID(link)
+		if id >= 0 {
+			t.Errorf("importedID(%q) = %d, want a negative id that cannot clash with Raindrop's", link, id)
+		}
+		if again := importedID(link); again != id {
+			t.Errorf("importedID(%q) = %d then %d, want the same id every time", link, id, again)
+		}
+	}
+	if importedID(links[0]) == importedID(links[1]) {
+		t.Errorf("different links got the same id %d", importedID(links[0]))
+	}
+}
+
+func TestReadBookmarksHTML(t *testing.T) {
+	raindrops, err := readBookmarksHTMLFile("testdata/bookmarks.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, raindrop := range raindrops {
+		got = append(got, raindrop.Title+" @ "+raindrop.CollectionPath)
+	}
+	want := []string{"Effective Go @ Reading/Go & Rust", "Tom & Jerry @ Reading", "Hacker News @ "}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got bookmarks %q, want %q", got, want)
+	}
+
+	effective := raindrops[0]
+	if effective.CollectionTitle != "Go & Rust" || strings.Join(effective.Tags, "|") != "go|docs" || effective.Domain != "go.dev" {
+		t.Errorf("Effective Go = %+v, want its folder, tags and domain", effective)
+	}
+	if effective.CreatedUnix != 1709287200 || !effective.LastUpdate.Equal(time.Unix(1709373600, 0)) {
+		t.Errorf("Effective Go created %d and updated %v, want ADD_DATE and LAST_MODIFIED", effective.CreatedUnix, effective.LastUpdate)
+	}
+	if link := raindrops[1].Link; link != "https://www.example.com/?a=1&b=2" {
+		t.Errorf("link = %q, want the entities unescaped", link)
+	}
+	if raindrops[2].ID != importedID("https://news.ycombinator.com/") || raindrops[2].CollectionTitle != "" {
+		t.Errorf("bookmark outside any folder = %+v, want an id from its link and no collection", raindrops[2])
+	}
+}
+
+func TestReadBookmarksHTMLInvalidDate(t *testing.T) {
+	_, err := readBookmarksHTML(`<DL><DT><A HREF="https://go.dev/" ADD_DATE="soon">Go</A></DL>`)
+	if err == nil || !strings.Contains(err.Error(), `invalid ADD_DATE "soon"`) {
+		t.Errorf("readBookmarksHTML() = %v, want an invalid ADD_DATE error", err)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/mattn/go-isatty"
+	"github.com/zpeters/dropsearch/searchindex"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// errNoSelection is returned when the user quits without choosing a result
+var errNoSelection = errors.New("no result selected")
+
+// isInteractive reports whether both stdin and stdout are terminals, so the
+// user can be prompted
+func isInteractive(stdin io.Reader, stdout io.Writer) bool {
+	return isTerminal(stdin) && isTerminal(stdout)
+}
+
+// stdinIsPiped reports whether stdin is a pipe, a file or any other reader
+// rather than a terminal
+func stdinIsPiped(stdin io.Reader) bool {
+	return stdin != nil && !isTerminal(stdin)
+}
+
+// isTerminal reports whether the reader or writer is a terminal, anything
+// other than an *os.File never is
+func isTerminal(stream any) bool {
+	f, ok := stream.(*os.File)
+	if !ok {
+		return false
+	}
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+// confirm asks a yes or no question on w and reads the answer from r, only y
+// or yes count as yes and the end of input counts as no
+func confirm(r io.Reader, w io.Writer, question string) (bool, error) {
+	fmt.Fprintf(w, "%s [y/N] ", question)
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, fmt.Errorf("error reading answer: %w", err)
+	}
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes", nil
+}
+
+// readQuery reads a search query from r, collapsing all whitespace including
+// newlines into single spaces
+func readQuery(r io.Reader) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("error reading query: %w", err)
+	}
+	return strings.Join(strings.Fields(string(data)), " "), nil
+}
+
+// selectHit prompts for a result number until a valid one is entered,
+// returning errNoSelection if the user quits or input ends. Results are
+// numbered from offset+1 like the printed list
+func selectHit(in io.Reader, out io.Writer, hits []searchindex.Hit, offset int64) (searchindex.Hit, error) {
+	if len(hits) == 0 {
+		return searchindex.Hit{}, errNoSelection
+	}
+
+	first, last := offset+1, offset+int64(len(hits))
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprintf(out, "Open result [%d-%d, q to quit]: ", first, last)
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			if err := scanner.Err(); err != nil {
+				return searchindex.Hit{}, err
+			}
+			return searchindex.Hit{}, errNoSelection
+		}
+
+		answer := strings.TrimSpace(scanner.Text())
+		switch strings.ToLower(answer) {
+		case "":
+			continue
+		case "q", "quit":
+			return searchindex.Hit{}, errNoSelection
+		}
+
+		n, err := strconv.ParseInt(answer, 10, 64)
+		if err != nil || n < first || n > last {
+			fmt.Fprintf(out, "'%s' isn't a result number\n", answer)
+			continue
+		}
+		return hits[n-first], nil
+	}
+}
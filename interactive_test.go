@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"github.com/zpeters/dropsearch/raindropio"
+	"github.com/zpeters/dropsearch/searchindex"
+	"strings"
+	"testing"
+)
+
+func TestSelectHit(t *testing.T) {
+	hits := []searchindex.Hit{
+		{Raindrop: raindropio.Raindrop{ID: 1}},
+		{Raindrop: raindropio.Raindrop{ID: 2}},
+	}
+	tests := []struct {
+		name  string
+		input string
+		want  int
+		err   error
+	}{
+		{"number", "12\n", 2, nil},
+		{"retry after bad answers", "\nfoo\n3\n11\n", 1, nil},
+		{"quit", "q\n", 0, errNoSelection},
+		{"end of input", "", 0, errNoSelection},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			hit, err := selectHit(strings.NewReader(test.input), &out, hits, 10)
+			if err != test.err || hit.ID != test.want {
+				t.Errorf("got hit %d and %v, want %d and %v", hit.ID, err, test.want, test.err)
+			}
+			if !strings.HasPrefix(out.String(), "Open result [11-12, q to quit]: ") {
+				t.Errorf("prompt = %q, want the numbers of the page", out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ansiEscape matches the color codes that would otherwise end up inside
+// structured log values
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// setupLogging sends logs to w in the given format, text or json. Quiet
+// raises the level so only errors are written
+func setupLogging(w io.Writer, format string, quiet bool) error {
+	level := slog.LevelInfo
+	if quiet {
+		level = slog.LevelError
+	}
+
+	var handler slog.Handler
+	switch format {
+	case "text":
+		handler = &lineHandler{w: w, level: level, mu: &sync.Mutex{}}
+	case "json":
+		handler = slog.NewJSONHandler(w, &slog.HandlerOptions{
+			Level: level,
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if a.Value.Kind() == slog.KindString {
+					a.Value = slog.StringValue(ansiEscape.ReplaceAllString(a.Value.String(), ""))
+				}
+				return a
+			},
+		})
+	default:
+		return fmt.Errorf("unknown log format %q, use text or json", format)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// lineHandler writes log records as plain lines in the same layout as the
+// standard log package, followed by any attributes as key=value pairs.
+// Messages are written as is so colored messages still render
+type lineHandler struct {
+	w     io.Writer
+	level slog.Level
+	attrs []slog.Attr
+	mu    *sync.Mutex
+}
+
+func (h *lineHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *lineHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	b.WriteString(r.Time.Format("2006/01/02 15:04:05 "))
+	if r.Level >= slog.LevelWarn {
+		b.WriteString(r.Level.String() + " ")
+	}
+	b.WriteString(r.Message)
+
+	writeAttr := func(a slog.Attr) bool {
+		fmt.Fprintf(&b, " %s=%v", a.Key, a.Value)
+		return true
+	}
+	for _, a := range h.attrs {
+		writeAttr(a)
+	}
+	r.Attrs(writeAttr)
+	b.WriteString("\n")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+func (h *lineHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	combined := append(append([]slog.Attr{}, h.attrs...), attrs...)
+	return &lineHandler{w: h.w, level: h.level, attrs: combined, mu: h.mu}
+}
+
+func (h *lineHandler) WithGroup(_ string) slog.Handler {
+	return h
+}
+
+// durationAttr keeps durations readable in both log formats
+func durationAttr(key string, d time.Duration) slog.Attr {
+	return slog.String(key, d.String())
+}
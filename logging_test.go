@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// captureLogs sets up logging into a buffer for the rest of the test
+func captureLogs(t *testing.T, format string, quiet bool) *bytes.Buffer {
+	t.Helper()
+	logger := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(logger) })
+
+	var b bytes.Buffer
+	err := setupLogging(&b, format, quiet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &b
+}
+
+func TestQuietOnlyLogsErrors(t *testing.T) {
+	logs := captureLogs(t, "text", true)
+
+	slog.Info("indexing started")
+	slog.Warn("rate limited by Raindrop")
+	slog.Error("meilisearch is unreachable")
+
+	got := logs.String()
+	if strings.Contains(got, "indexing started") || strings.Contains(got, "rate limited") {
+		t.Errorf("quiet logging wrote informational messages:\n%s", got)
+	}
+	if !strings.Contains(got, "ERROR meilisearch is unreachable") {
+		t.Errorf("quiet logging dropped the error:\n%s", got)
+	}
+}
+
+func TestLogsWithoutQuiet(t *testing.T) {
+	logs := captureLogs(t, "text", false)
+
+	slog.Info("indexing started", "index", "raindrops")
+
+	if got := logs.String(); !strings.HasSuffix(got, " indexing started index=raindrops\n") {
+		t.Errorf("got %q, want the message and its attributes", got)
+	}
+}
+
+func TestJSONLogs(t *testing.T) {
+	logs := captureLogs(t, "json", false)
+
+	slog.Info("found \x1b[33m3\x1b[0m hits", "index", "raindrops", durationAttr("took", 1500*time.Millisecond))
+
+	var record map[string]interface{}
+	err := json.Unmarshal(logs.Bytes(), &record)
+	if err != nil {
+		t.Fatalf("log line isn't JSON: %v\n%s", err, logs)
+	}
+	if record["msg"] != "found 3 hits" || record["index"] != "raindrops" || record["took"] != "1.5s" || record["level"] != "INFO" {
+		t.Errorf("got %v, want the message without colors and the attributes", record)
+	}
+}
+
+func TestUnknownLogFormat(t *testing.T) {
+	if err := setupLogging(io.Discard, "xml", false); err == nil {
+		t.Error("setupLogging accepted an unknown format")
+	}
+}
@@ -1,270 +1,1466 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/briandowns/spinner"
 	"github.com/fatih/color"
 	"github.com/meilisearch/meilisearch-go"
+	"github.com/zpeters/dropsearch/raindropio"
+	"github.com/zpeters/dropsearch/searchindex"
 	"io"
-	"log"
-	"net/http"
+	"log/slog"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
 	"time"
 )
 
-type RaindropCollection struct {
-	ID            int       `json:"_id"`
-	Access        Access    `json:"access"`
-	Collaborators struct{}  `json:"collaborators"` // Assuming you don't need details here
-	Color         string    `json:"color"`
-	Count         int       `json:"count"`
-	Cover         []string  `json:"cover"`
-	Created       time.Time `json:"created"`
-	Expanded      bool      `json:"expanded"`
-	LastUpdate    time.Time `json:"lastUpdate"`
-	Parent        *Parent   `json:"parent"` // Optional, hence a pointer
-	Public        bool      `json:"public"`
-	Sort          int       `json:"sort"`
-	Title         string    `json:"title"`
-	User          User      `json:"user"`
-	View          string    `json:"view"`
-}
-
-type Access struct {
-	Level     int  `json:"level"`
-	Draggable bool `json:"draggable"`
-}
-
-type Parent struct {
-	ID int `json:"$id"`
-}
-
-type User struct {
-	ID int `json:"$id"`
-}
-
-type RaindropCollectionResponse struct {
-	Result      bool                 `json:"result"`
-	Collections []RaindropCollection `json:"items"`
-}
-
-type Raindrop struct {
-	ID         int `json:"_id"`
-	Collection struct {
-		ID int `json:"$id"`
-	} `json:"collection"`
-	Cover      string    `json:"cover"`
-	Created    time.Time `json:"created"`
-	Domain     string    `json:"domain"`
-	Excerpt    string    `json:"excerpt"`
-	Note       string    `json:"note"`
-	LastUpdate time.Time `json:"lastUpdate"`
-	Link       string    `json:"link"`
-	Media      []struct {
-		Link string `json:"link"`
-	} `json:"media"`
-	Tags  []string `json:"tags"`
-	Title string   `json:"title"`
-	Type  string   `json:"type"`
-	User  struct {
-		ID int `json:"$id"`
-	} `json:"user"`
-	Broken bool `json:"broken"`
-	Cache  struct {
-		Status  string    `json:"status"`
-		Size    int       `json:"size"`
-		Created time.Time `json:"created"`
-	} `json:"cache"`
-	CreatorRef struct {
-		ID       int    `json:"_id"`
-		FullName string `json:"fullName"`
-	} `json:"creatorRef"`
-	File struct {
-		Name string `json:"name"`
-		Size int    `json:"size"`
-		Type string `json:"type"`
-	} `json:"file"`
-	Important  bool `json:"important"`
-	Highlights []struct {
-		ID      string    `json:"_id"`
-		Text    string    `json:"text"`
-		Color   string    `json:"color"`
-		Note    string    `json:"note"`
-		Created time.Time `json:"created"`
-	} `json:"highlights"`
-}
-
-type RaindropsResponse struct {
-	Items []Raindrop `json:"items"`
+// version, commit and date describe the build, they are set with
+// -ldflags "-X main.version=... -X main.commit=... -X main.date=..."
+var (
+	version = "dev"
+	commit  = "dev"
+	date    = "dev"
+)
+
+// versionString describes the build for -version and bug reports
+func versionString() string {
+	return fmt.Sprintf("dropsearch %s (commit %s, built %s)", version, commit, date)
 }
 
 func main() {
-	indexFlag := flag.Bool("i", false, "Index bookmarks")
-	flag.Parse()
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run carries out the command given by args, reading queries and answers
+// from stdin, writing results to stdout and errors and logs to stderr, and
+// returns the exit code
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	// colors and the logger are package wide, put them back so one run
+	// doesn't leak its settings into the next
+	defer func(noColor bool, logger *slog.Logger) {
+		color.NoColor = noColor
+		slog.SetDefault(logger)
+	}(color.NoColor, slog.Default())
+
+	err := execute(args, stdin, stdout, stderr)
+	var usageErr usageError
+	switch {
+	case err == nil, errors.Is(err, errNoHits), errors.Is(err, flag.ErrHelp):
+		return exitCode(err)
+	case errors.Is(err, errUsageShown):
+	case errors.As(err, &usageErr):
+		fmt.Fprintln(stderr, err)
+	default:
+		slog.Error(err.Error())
+	}
+	return exitCode(err)
+}
+
+// execute parses the flags and carries out the requested command
+func execute(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	flags := flag.NewFlagSet("dropsearch", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: dropsearch [flags] [search query]")
+		flags.PrintDefaults()
+	}
+	indexFlag := flags.Bool("i", false, "Index bookmarks")
+	indexHighlightsFlag := flags.Bool("index-highlights", false, "Also index each highlight as its own document in the highlights index, use with -i")
+	highlightsFlag := flags.Bool("highlights", false, "Search the highlights index instead of bookmarks")
+	highlightsIndexFlag := flags.String("highlights-index", defaultHighlightsIndex, "Meilisearch index for -index-highlights and -highlights")
+	versionFlag := flags.Bool("version", false, "Print version information")
+	completionFlag := flags.String("completion", "", "Print a completion script for "+strings.Join(completionShells, ", "))
+	collectionsFlag := flags.Bool("collections", false, "List collections")
+	tagsListFlag := flags.Bool("tags", false, "List tags")
+	brokenFlag := flags.Bool("broken", false, "List bookmarks with broken links")
+	suggestTagsFlag := flags.String("suggest-tags", "", "Print the tags starting with this prefix, one per line, for shell completion")
+	lastRunFlag := flags.Bool("last-run", false, "Show when the index was last updated and how the run went")
+	statsFlag := flags.Bool("stats", false, "Show index statistics")
+	healthFlag := flags.Bool("health", false, "Check Meilisearch is reachable and the token works")
+	var queryFlag string
+	flags.StringVar(&queryFlag, "query", "", "Search query, used instead of any arguments")
+	flags.StringVar(&queryFlag, "q", "", "Shorthand for -query")
+	limitFlag := limitValue(10)
+	flags.Var(&limitFlag, "limit", "Maximum number of search results, 0 or all for every result up to -max-results")
+	maxResultsFlag := flags.Int("max-results", searchindex.DefaultMaxResults, "Most results fetched by -limit 0, to stop runaway queries")
+	offsetFlag := flags.Int("offset", 0, "Number of search results to skip")
+	openFlag := flags.Int("open", 0, "Open search result number n in the browser")
+	firstFlag := flags.Bool("first", false, "Open the top search result in the browser, same as -open with the first number on the page")
+	interactiveFlag := flags.Bool("interactive", false, "Choose a search result to open after searching")
+	formatFlag := flags.String("format", "text", "Search output format: "+strings.Join(rendererNames(), ", "))
+	jsonFlag := flags.Bool("json", false, "Print search results as JSON, same as -format json")
+	fieldsFlag := flags.String("fields", strings.Join(defaultTextFields, ","), "Comma separated fields shown in text output, any of "+strings.Join(textFields, ","))
+	explainFlag := flags.Bool("explain", false, "Show the ranking score and matched fields of each search result")
+	minScoreFlag := flags.Float64("min-score", 0, "Drop search results with a ranking score below this, from 0 to 1")
+	countFlag := flags.Bool("count", false, "Only print the number of matching bookmarks")
+	showCacheFlag := flags.Bool("show-cache", false, "Show the status of Raindrop's cached copy, same as adding cache to -fields")
+	showFaviconFlag := flags.Bool("show-favicon", false, "Show each result's favicon in markdown and html output")
+	faviconServiceFlag := flags.String("favicon-service", searchindex.DefaultFaviconService, "Favicon URL stored while indexing, {domain} is replaced with the bookmark's domain, empty stores none")
+	showCoverFlag := flags.Bool("show-cover", false, "Show the cover image URL, same as adding cover to -fields")
+	outputFlag := flags.String("output", "", "Write results to this file instead of stdout")
+	legendFlag := flags.Bool("legend", false, "Print the title color used for each bookmark type")
+	noColorFlag := flags.Bool("no-color", false, "Disable colored output")
+	timeoutFlag := flags.Duration("timeout", 30*time.Second, "Overall timeout for indexing")
+	requestTimeoutFlag := flags.Duration("request-timeout", 0, "Timeout for each Raindrop and Meilisearch request, 0 leaves only -timeout")
+	batchSizeFlag := flags.Int("batch-size", 1000, "Number of documents sent to meilisearch per request")
+	concurrencyFlag := flags.Int("concurrency", 4, "Number of collections fetched in parallel while indexing")
+	searchableFlag := flags.String("searchable", strings.Join(searchindex.DefaultSearchableAttributes, ","), "Comma separated fields used for search ranking")
+	displayedFlag := flags.String("displayed", strings.Join(searchindex.DefaultDisplayedAttributes, ","), "Comma separated fields returned in search results")
+	logFormatFlag := flags.String("log-format", "text", "Log format, text or json")
+	quietFlag := flags.Bool("quiet", false, "Only print errors, without progress output or informational logs")
+	dedupeURLFlag := flags.Bool("dedupe-url", false, "Index only the earliest saved bookmark of each link, ignoring case, trailing slashes and tracking parameters")
+	summaryFlag := flags.Bool("summary", false, "Print how many documents came from each collection after indexing")
+	noSpinnerFlag := flags.Bool("no-spinner", false, "Don't animate indexing progress but keep logging, the spinner is always off when stdout isn't a terminal")
+	oneTypoFlag := flags.Int64("min-word-size-one-typo", searchindex.DefaultMinWordSizeOneTypo, "Minimum word length that tolerates one typo")
+	twoTyposFlag := flags.Int64("min-word-size-two-typos", searchindex.DefaultMinWordSizeTwoTypos, "Minimum word length that tolerates two typos")
+	synonymsFlag := flags.String("synonyms", "", "JSON file mapping terms to their synonyms, applied while indexing")
+	stopWordsFlag := flags.String("stop-words", "", "File with one stop word per line applied while indexing, or default for a built-in English list")
+	createdAfterFlag := flags.String("created-after", "", "Only index bookmarks created on or after this date (YYYY-MM-DD or RFC3339)")
+	fetchCacheFlag := flags.Bool("fetch-cache", false, "Download Raindrop's cached copy of each page and index its text, this is slow so raise -timeout to match")
+	normalizeTagsFlag := flags.Bool("normalize-tags", false, "Lowercase and trim tags while indexing so differently cased tags match")
+	skipBrokenFlag := flags.Bool("skip-broken", false, "Don't index bookmarks with broken links")
+	collectionsTTLFlag := flags.Duration("collections-ttl", time.Hour, "How long the cached collections list is reused")
+	refreshCollectionsFlag := flags.Bool("refresh-collections", false, "Fetch the collections list even if the cache is fresh")
+	reindexIDFlag := flags.Int("reindex-id", 0, "Fetch and index only the bookmark with this id")
+	importCSVFlag := flags.String("import-csv", "", "Index bookmarks from a Raindrop CSV export instead of the API")
+	importHTMLFlag := flags.String("import-html", "", "Index bookmarks from a browser bookmarks HTML export instead of the API")
+	resetIndexFlag := flags.Bool("reset-index", false, "Delete the index and create it again empty before indexing, asks first unless -yes is given")
+	yesFlag := flags.Bool("yes", false, "Don't ask before -reset-index deletes the index")
+	dryRunFlag := flags.Bool("dry-run", false, "Fetch bookmarks and report what would be indexed without changing the index")
+	sinceFlag := flags.Bool("since", false, "Only index bookmarks changed since the last index run")
+	var tagsFlag stringList
+	flags.Var(&tagsFlag, "tag", "Only return bookmarks with this tag, can be repeated")
+	tagMatchFlag := flags.String("tag-match", "all", "How -tag filters combine, all or any")
+	var collectionsFilterFlag intList
+	flags.Var(&collectionsFilterFlag, "collection", "Only index or search this collection id, can be repeated")
+	var excludeCollectionsFlag stringList
+	flags.Var(&excludeCollectionsFlag, "collection-exclude", "Don't index the collection with this id or title, can be repeated")
+	afterFlag := flags.String("after", "", "Only return bookmarks created on or after this date (YYYY-MM-DD or RFC3339)")
+	beforeFlag := flags.String("before", "", "Only return bookmarks created on or before this date (YYYY-MM-DD or RFC3339)")
+	importantFlag := flags.Bool("important", false, "Only return bookmarks marked as favorites in Raindrop")
+	domainFlag := flags.String("domain", "", "Only return bookmarks from this domain")
+	typeFlag := flags.String("type", "", "Only return bookmarks of this type, like article or video")
+	cacheStatusFlag := flags.String("cache-status", "", "Only return bookmarks whose cached copy has this status, like ready or failed")
+	sortFlag := flags.String("sort", "", "Sort search results, created:asc or created:desc")
+	noTiebreakFlag := flags.Bool("no-tiebreak", false, "Leave equally relevant results in meilisearch's order instead of newest first")
+	matchFlag := flags.String("match", "", "How multi-word queries match, last (meilisearch's default) or all to require every word")
+	retriesFlag := flags.Int("retries", raindropio.DefaultRetries, "Number of retries for failed Raindrop and meilisearch requests")
+	raindropURLFlag := flags.String("raindrop-url", raindropio.DefaultURL, "Raindrop API base URL")
+	allIndexesFlag := flags.Bool("all-indexes", false, "Search the index of every profile in the config file and merge the results by score")
+	profileFlag := flags.String("profile", "", "Named profile from the config file, overrides DROPSEARCH_PROFILE")
+	var flagConfig Config
+	flags.StringVar(&flagConfig.RaindropToken, "raindrop-token", "", "Raindrop API token, overrides DROPSEARCH_RAINDROP_TOKEN")
+	flags.StringVar(&flagConfig.SearchToken, "search-token", "", "Meilisearch API key, overrides DROPSEARCH_MEILISEARCH_TOKEN")
+	waitForMeiliFlag := flags.Duration("wait-for-meili", 0, "Wait up to this long for Meilisearch to become healthy before starting, like 30s")
+	raindropTokenFileFlag := flags.String("raindrop-token-file", "", "File holding the Raindrop API token, overrides DROPSEARCH_RAINDROP_TOKEN")
+	searchTokenFileFlag := flags.String("search-token-file", "", "File holding the Meilisearch API key, overrides DROPSEARCH_MEILISEARCH_TOKEN")
+	flags.StringVar(&flagConfig.Host, "host", "", "Meilisearch host, overrides DROPSEARCH_MEILISEARCH_HOST")
+	flags.StringVar(&flagConfig.Index, "index", "", "Meilisearch index name, overrides DROPSEARCH_INDEX")
+	err := flags.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+	if err != nil {
+		return errUsageShown
+	}
 
-	var raindropToken = os.Getenv("DROPSEARCH_RAINDROP_TOKEN")
-	var searchToken = os.Getenv("DROPSEARCH_MEILISEARCH_TOKEN")
+	err = setupLogging(stderr, *logFormatFlag, *quietFlag)
+	if err != nil {
+		return usageErrorf("-log-format: %w", err)
+	}
+
+	if *versionFlag {
+		fmt.Fprintln(stdout, versionString())
+		return nil
+	}
+
+	if *completionFlag != "" {
+		err := writeCompletion(stdout, *completionFlag, flags)
+		if err != nil {
+			return usageErrorf("-completion: %w", err)
+		}
+		return nil
+	}
+
+	raindropURL, err := raindropio.ParseBaseURL(*raindropURLFlag)
+	if err != nil {
+		return usageErrorf("-raindrop-url: %w", err)
+	}
+
+	if *jsonFlag {
+		*formatFlag = "json"
+	}
+	resultRenderer, err := newRenderer(*formatFlag)
+	if err != nil {
+		return usageErrorf("-format: %w", err)
+	}
+
+	fields, err := parseFields(*fieldsFlag)
+	if err != nil {
+		return usageErrorf("-fields: %w", err)
+	}
+	if *showCacheFlag {
+		fields["cache"] = true
+	}
+	if *showCoverFlag {
+		fields["cover"] = true
+	}
+	if _, ok := resultRenderer.(textRenderer); ok {
+		resultRenderer = textRenderer{Fields: fields, Explain: *explainFlag}
+	}
+	switch resultRenderer.(type) {
+	case markdownRenderer:
+		resultRenderer = markdownRenderer{Favicon: *showFaviconFlag}
+	case htmlRenderer:
+		resultRenderer = htmlRenderer{Favicon: *showFaviconFlag}
+	}
+
+	if *formatFlag != "text" || *noColorFlag || *outputFlag != "" || os.Getenv("NO_COLOR") != "" {
+		color.NoColor = true
+	}
+
+	if *outputFlag != "" {
+		f, err := createOutput(*outputFlag)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		stdout = f
+	}
+
+	if *legendFlag {
+		printLegend(stdout)
+		return nil
+	}
+
+	err = flagConfig.readTokenFiles(*raindropTokenFileFlag, *searchTokenFileFlag)
+	if err != nil {
+		return err
+	}
+	config, err := loadConfig(flagConfig, *profileFlag)
+	if err != nil {
+		return err
+	}
+	raindropClient := raindropio.NewClient(config.RaindropToken)
+	raindropClient.BaseURL = raindropURL
+	raindropClient.Retries = *retriesFlag
+	if *requestTimeoutFlag < 0 {
+		return usageErrorf("-request-timeout can't be negative")
+	}
+	raindropClient.HTTPClient.Timeout = *requestTimeoutFlag
+
+	// without a config dir the collections list simply isn't cached
+	stateID := indexID{Host: config.Host, Index: config.Index}
+	cachePath, _ := collectionCachePath(stateID)
+	cache := collectionCache{
+		Path:    cachePath,
+		TTL:     *collectionsTTLFlag,
+		Refresh: *refreshCollectionsFlag,
+	}
 
 	client := meilisearch.NewClient(meilisearch.ClientConfig{
-		Host:   "http://search",
-		APIKey: searchToken,
+		Host:    config.Host,
+		APIKey:  config.SearchToken,
+		Timeout: *requestTimeoutFlag,
 	})
+	if *waitForMeiliFlag > 0 {
+		err := waitForMeilisearch(client, *waitForMeiliFlag)
+		if err != nil {
+			return err
+		}
+	}
+
+	settings := searchindex.Settings{
+		Searchable:          splitList(*searchableFlag),
+		Displayed:           splitList(*displayedFlag),
+		MinWordSizeOneTypo:  *oneTypoFlag,
+		MinWordSizeTwoTypos: *twoTyposFlag,
+	}
+	if *indexFlag || *importCSVFlag != "" || *importHTMLFlag != "" {
+		err := settings.Validate()
+		if err != nil {
+			return usageErrorf("-min-word-size-one-typo and -min-word-size-two-typos: %w", err)
+		}
+		if *synonymsFlag != "" {
+			settings.Synonyms, err = readSynonyms(*synonymsFlag)
+			if err != nil {
+				return usageErrorf("-synonyms: %w", err)
+			}
+		}
+		if *stopWordsFlag != "" {
+			settings.StopWords, err = readStopWords(*stopWordsFlag)
+			if err != nil {
+				return usageErrorf("-stop-words: %w", err)
+			}
+		}
+	}
+
+	if *resetIndexFlag {
+		if *dryRunFlag {
+			return usageErrorf("-reset-index can't be used with -dry-run")
+		}
+		err := validateTokens(config, false, true)
+		if err != nil {
+			return err
+		}
+		if !*yesFlag {
+			ok, err := confirm(stdin, stderr, fmt.Sprintf("Delete index %q and all of its documents?", config.Index))
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return errors.New("index reset cancelled")
+			}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), *timeoutFlag)
+		defer cancel()
+		err = searchindex.ResetIndex(ctx, client, config.Index)
+		if err != nil {
+			return err
+		}
+		// the index is empty, so an incremental run has to start over
+		err = forgetLastUpdate(stateID)
+		if err != nil {
+			return err
+		}
+		slog.Info("index reset", "index", config.Index)
+		if !*indexFlag && *importCSVFlag == "" && *importHTMLFlag == "" {
+			return nil
+		}
+	}
 
 	if *indexFlag {
-		indexBookmarks(client, raindropToken)
-		return
+		err := validateTokens(config, true, true)
+		if err != nil {
+			return err
+		}
+		var createdAfter time.Time
+		if *createdAfterFlag != "" {
+			createdAfter, err = parseDate(*createdAfterFlag, false)
+			if err != nil {
+				return usageErrorf("-created-after: %w", err)
+			}
+		}
+		var highlightsIndex string
+		if *indexHighlightsFlag {
+			highlightsIndex = *highlightsIndexFlag
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		ctx, cancel := context.WithTimeout(ctx, *timeoutFlag)
+		defer cancel()
+		return indexBookmarks(ctx, stdout, client, raindropClient, indexOptions{
+			Host:               config.Host,
+			IndexName:          config.Index,
+			Concurrency:        *concurrencyFlag,
+			CollectionCache:    cache,
+			DryRun:             *dryRunFlag,
+			BatchSize:          *batchSizeFlag,
+			Retries:            *retriesFlag,
+			NormalizeTags:      *normalizeTagsFlag,
+			FetchCache:         *fetchCacheFlag,
+			Incremental:        *sinceFlag,
+			Collections:        collectionsFilterFlag,
+			SkipBroken:         *skipBrokenFlag,
+			CreatedAfter:       createdAfter,
+			Settings:           settings,
+			Spinner:            showSpinner(stdout, *quietFlag, *noSpinnerFlag),
+			HighlightsIndex:    highlightsIndex,
+			FaviconService:     *faviconServiceFlag,
+			ExcludeCollections: excludeCollectionsFlag,
+			DedupeURL:          *dedupeURLFlag,
+			Summary:            *summaryFlag,
+		})
+	}
+
+	if *reindexIDFlag != 0 {
+		err := validateTokens(config, true, true)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), *timeoutFlag)
+		defer cancel()
+		return reindexBookmark(ctx, client, raindropClient, *reindexIDFlag, indexOptions{
+			IndexName:       config.Index,
+			CollectionCache: cache,
+			Retries:         *retriesFlag,
+			NormalizeTags:   *normalizeTagsFlag,
+			FaviconService:  *faviconServiceFlag,
+			FetchCache:      *fetchCacheFlag,
+		})
 	}
 
-	searchQuery := strings.Join(flag.Args(), " ")
+	if *importCSVFlag != "" || *importHTMLFlag != "" {
+		err := validateTokens(config, false, true)
+		if err != nil {
+			return err
+		}
+		var raindrops []raindropio.Raindrop
+		if *importCSVFlag != "" {
+			raindrops, err = readRaindropCSVFile(*importCSVFlag)
+		} else {
+			raindrops, err = readBookmarksHTMLFile(*importHTMLFlag)
+		}
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), *timeoutFlag)
+		defer cancel()
+		return importBookmarks(ctx, stdout, client, raindrops, indexOptions{
+			IndexName:      config.Index,
+			DryRun:         *dryRunFlag,
+			BatchSize:      *batchSizeFlag,
+			Retries:        *retriesFlag,
+			NormalizeTags:  *normalizeTagsFlag,
+			FaviconService: *faviconServiceFlag,
+			Settings:       settings,
+		})
+	}
+
+	if *collectionsFlag {
+		err := validateTokens(config, true, false)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), *timeoutFlag)
+		defer cancel()
+		collections, err := getCachedCollections(ctx, raindropClient, cache)
+		if err != nil {
+			return err
+		}
+		printCollections(stdout, collections)
+		return nil
+	}
+
+	if *tagsListFlag {
+		err := validateTokens(config, true, false)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), *timeoutFlag)
+		defer cancel()
+		tags, err := raindropClient.Tags(ctx)
+		if err != nil {
+			return err
+		}
+		printTags(stdout, tags)
+		return nil
+	}
+
+	if *suggestTagsFlag != "" {
+		err := validateTokens(config, true, false)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), *timeoutFlag)
+		defer cancel()
+		tags, err := raindropClient.Tags(ctx)
+		if err != nil {
+			return err
+		}
+		for _, tag := range tagsWithPrefix(tags, *suggestTagsFlag) {
+			fmt.Fprintln(stdout, tag.Name)
+		}
+		return nil
+	}
+
+	if *brokenFlag {
+		err := validateTokens(config, true, false)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), *timeoutFlag)
+		defer cancel()
+		return listBroken(ctx, stdout, raindropClient, cache, *concurrencyFlag)
+	}
+
+	if *healthFlag {
+		err := validateTokens(config, false, true)
+		if err != nil {
+			return err
+		}
+		version, err := checkHealth(client)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, "OK, meilisearch", version, "at", config.Host)
+		return nil
+	}
+
+	if *statsFlag {
+		err := validateTokens(config, false, true)
+		if err != nil {
+			return err
+		}
+		return showStats(stdout, client, config.Index)
+	}
+
+	if *lastRunFlag {
+		return printLastRun(stdout, stateID)
+	}
+
+	searchQuery := strings.Join(flags.Args(), " ")
+	if queryFlag != "" {
+		if searchQuery != "" {
+			slog.Warn("using -query, ignoring the search arguments", "arguments", searchQuery)
+		}
+		searchQuery = queryFlag
+	}
+	if searchQuery == "" && stdinIsPiped(stdin) {
+		searchQuery, err = readQuery(stdin)
+		if err != nil {
+			return err
+		}
+	}
 	if searchQuery != "" {
-		searchBookmarks(client, searchQuery)
-		return
+		if limitFlag < 0 {
+			return usageErrorf("limit must be a positive number of results, or 0 for all")
+		}
+		if *maxResultsFlag <= 0 {
+			return usageErrorf("-max-results must be a positive number of results")
+		}
+		if *offsetFlag < 0 {
+			return usageErrorf("offset can't be negative")
+		}
+		sortRules, err := parseSort(*sortFlag)
+		if err != nil {
+			return usageErrorf("-sort: %w", err)
+		}
+		if len(sortRules) > 0 && *allIndexesFlag {
+			return usageErrorf("-sort can't be used with -all-indexes, which merges the results by score")
+		}
+		if *minScoreFlag < 0 || *minScoreFlag > 1 {
+			return usageErrorf("-min-score must be between 0 and 1")
+		}
+		if *minScoreFlag > 0 && *countFlag {
+			return usageErrorf("-min-score can't be used with -count, which only sees meilisearch's estimate")
+		}
+		matchingStrategy, err := parseMatchingStrategy(*matchFlag)
+		if err != nil {
+			return usageErrorf("-match: %w", err)
+		}
+		anyTag, err := parseTagMatch(*tagMatchFlag)
+		if err != nil {
+			return usageErrorf("-tag-match: %w", err)
+		}
+		raindropType := strings.ToLower(strings.TrimSpace(*typeFlag))
+		_, err = searchindex.TypeFilter(raindropType)
+		if err != nil {
+			return usageErrorf("-type: %w", err)
+		}
+		_, err = searchindex.CacheStatusFilter(*cacheStatusFlag)
+		if err != nil {
+			return usageErrorf("-cache-status: %w", err)
+		}
+		var after, before time.Time
+		if *afterFlag != "" {
+			after, err = parseDate(*afterFlag, false)
+			if err != nil {
+				return usageErrorf("-after: %w", err)
+			}
+		}
+		if *beforeFlag != "" {
+			before, err = parseDate(*beforeFlag, true)
+			if err != nil {
+				return usageErrorf("-before: %w", err)
+			}
+		}
+		if *firstFlag {
+			*openFlag = *offsetFlag + 1
+		}
+		opts := searchOptions{
+			Query: searchindex.Query{
+				Limit:        int64(limitFlag),
+				MaxResults:   int64(*maxResultsFlag),
+				Offset:       int64(*offsetFlag),
+				Tags:         tagsFlag,
+				AnyTag:       anyTag,
+				Collections:  collectionsFilterFlag,
+				After:        after,
+				Before:       before,
+				Domain:       strings.ToLower(strings.TrimSpace(*domainFlag)),
+				Type:         raindropType,
+				CacheStatus:  *cacheStatusFlag,
+				Sort:         sortRules,
+				Tiebreak:     sortRules == nil && !*noTiebreakFlag,
+				Match:        matchingStrategy,
+				RankingScore: *explainFlag,
+				MinScore:     *minScoreFlag,
+				Important:    *importantFlag,
+			},
+			IndexName:   config.Index,
+			Count:       *countFlag,
+			Open:        *openFlag,
+			Interactive: *interactiveFlag && isInteractive(stdin, stdout) && *outputFlag == "",
+			Stdin:       stdin,
+			Renderer:    resultRenderer,
+		}
+		if *highlightsFlag {
+			if *allIndexesFlag {
+				return usageErrorf("-highlights can't be used with -all-indexes")
+			}
+			if option := unsupportedHighlightsOption(opts); option != "" {
+				return usageErrorf("-highlights can't be used with %s", option)
+			}
+		}
+
+		// flag values are checked first so a mistake is a usage error even
+		// before the tokens are set up
+		err = validateTokens(config, false, true)
+		if err != nil {
+			return err
+		}
+		if *highlightsFlag {
+			return searchHighlights(stdout, client, *highlightsIndexFlag, searchQuery, opts)
+		}
+		if *allIndexesFlag {
+			names, configs, err := profileConfigs(config)
+			if err != nil {
+				return err
+			}
+			targets := make([]indexTarget, 0, len(configs))
+			for i, profileConfig := range configs {
+				targets = append(targets, indexTarget{
+					Profile: names[i],
+					Client: meilisearch.NewClient(meilisearch.ClientConfig{
+						Host:    profileConfig.Host,
+						APIKey:  profileConfig.SearchToken,
+						Timeout: *requestTimeoutFlag,
+					}),
+					Index: profileConfig.Index,
+				})
+			}
+			return searchAllIndexes(stdout, targets, searchQuery, opts)
+		}
+		return searchBookmarks(stdout, client, searchQuery, opts)
+	}
+
+	flags.Usage()
+	return errUsageShown
+}
+
+// createOutput creates or truncates the -output file, along with any missing
+// parent directories
+func createOutput(path string) (*os.File, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, fmt.Errorf("error creating output directory: %w", err)
 	}
 
-	fmt.Println("Usage: dropsearch [-i] [search query]")
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("error creating output file: %w", err)
+	}
+	return f, nil
+}
+
+// indexOptions controls how bookmarks are fetched and indexed
+type indexOptions struct {
+	// Host is the meilisearch host, it keeps the saved state of indexes with
+	// the same name on different hosts apart
+	Host string
+	// IndexName is the meilisearch index documents are added to
+	IndexName string
+	// Concurrency is the number of collections fetched in parallel
+	Concurrency int
+	// DryRun fetches and reports everything without touching meilisearch
+	DryRun bool
+	// CollectionCache controls reuse of the cached collections list
+	CollectionCache collectionCache
+	// BatchSize is the number of documents sent to meilisearch per request
+	BatchSize int
+	// NormalizeTags lowercases and trims tags, dropping repeats
+	NormalizeTags bool
+	// FetchCache downloads the cached copy of each page and indexes its text
+	FetchCache bool
+	// Retries is how many times a batch is resent after a transient
+	// meilisearch error
+	Retries int
+	// Incremental skips bookmarks that haven't changed since the last run
+	Incremental bool
+	// SkipBroken leaves bookmarks with broken links out of the index
+	SkipBroken bool
+	// CreatedAfter leaves bookmarks created before it out of the index, a
+	// zero time keeps everything
+	CreatedAfter time.Time
+	// Collections restricts indexing to these collection ids, empty means all
+	Collections []int
+	// ExcludeCollections leaves out collections with these ids or titles
+	ExcludeCollections []string
+	// DedupeURL indexes only the earliest created bookmark of each link
+	DedupeURL bool
+	// Settings are applied to the index before documents are added
+	Settings searchindex.Settings
+	// Spinner animates progress while indexing
+	Spinner bool
+	// HighlightsIndex also indexes every highlight as its own document in
+	// this index, empty leaves highlights only in their bookmarks
+	HighlightsIndex string
+	// Summary prints the documents indexed from each collection when done
+	Summary bool
+	// FaviconService is the favicon URL with a {domain} placeholder, empty
+	// leaves favicons out
+	FaviconService string
+}
+
+// indexID identifies the index the options add documents to
+func (o indexOptions) indexID() indexID {
+	return indexID{Host: o.Host, Index: o.IndexName}
 }
 
-func indexBookmarks(client *meilisearch.Client, raindropToken string) {
-	log.Println("indexing started")
-	s := spinner.New(spinner.CharSets[35], 100*time.Millisecond)
+// splitList splits a comma separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func indexBookmarks(ctx context.Context, w io.Writer, client *meilisearch.Client, raindropClient *raindropio.Client, opts indexOptions) (err error) {
+	slog.Info("indexing started", "index", opts.IndexName)
+
+	var sender *searchindex.Sender
+	if !opts.DryRun {
+		started := time.Now()
+		defer func() {
+			var sent int
+			if sender != nil {
+				sent = sender.Sent()
+			}
+			recordErr := saveLastRun(opts.indexID(), newRunRecord(started, time.Now(), sent, err))
+			if recordErr != nil {
+				slog.Warn("error saving the last run", "error", recordErr)
+			}
+		}()
+	}
+
+	// check the token first so a bad one fails before anything slow happens
+	account, err := raindropClient.User(ctx)
+	if err != nil {
+		return err
+	}
+	slog.Info("authenticated with Raindrop", "email", account.Email)
+
+	s := spinner.New(spinner.CharSets[35], 100*time.Millisecond, spinner.WithWriter(w))
 	s.Color("fgHiGreen")
 	s.Prefix = color.HiCyanString("Indexing: ")
-	s.Start()
+	if opts.Spinner {
+		s.Start()
+	}
 	defer s.Stop()
 
 	s.Suffix = " getting collections list"
-	collections, err := getCollections(raindropToken)
+	allCollections, err := getCachedCollections(ctx, raindropClient, opts.CollectionCache)
 	if err != nil {
-		log.Fatalln(err)
+		return err
 	}
 
-	var allRaindrops []Raindrop
+	collections := allCollections
+	if len(opts.Collections) > 0 {
+		collections, err = selectCollections(allCollections, opts.Collections)
+		if err != nil {
+			return err
+		}
+	}
+	collections = excludeCollections(collections, opts.ExcludeCollections)
+
+	state, err := loadIndexState(opts.indexID())
+	if err != nil {
+		return err
+	}
+
+	index := client.Index(opts.IndexName)
+	if !opts.DryRun {
+		s.Suffix = " configuring meilisearch index"
+		err = ensureIndex(ctx, client, opts.IndexName)
+		if err != nil {
+			return err
+		}
+		err = searchindex.Configure(index, opts.Settings)
+		if err != nil {
+			return err
+		}
+	}
+
+	total := 0
 	for _, collection := range collections {
-		s.Suffix = fmt.Sprintf(" getting raindrops for '%s'", collection.Title)
-		raindrops, err := getRaindropsInCollection(collection.ID, raindropToken)
+		total += collection.Count
+	}
+
+	var (
+		titles           = searchindex.CollectionTitles(allCollections)
+		paths            = searchindex.CollectionPaths(allCollections)
+		dedupe           = searchindex.NewDeduper()
+		counts           = make(map[int]int, len(collections))
+		highlights       []searchindex.HighlightDocument
+		fetchedRaindrops []raindropio.Raindrop
+		fetched          int
+		broken           int
+		skipped          int
+		latest           time.Time
+	)
+	fetchContent := opts.FetchCache && !opts.DryRun
+	sender = searchindex.NewSender(client, opts.IndexName, opts.BatchSize)
+	sender.DryRun = opts.DryRun
+	sender.Retries = opts.Retries
+
+	s.Suffix = " getting raindrops " + progressBar(fetched, total)
+	err = fetchRaindrops(ctx, raindropClient, collections, opts.Concurrency, func(collection raindropio.Collection, raindrops []raindropio.Raindrop) error {
+		fetched += len(raindrops)
+		s.Lock()
+		s.Suffix = " getting raindrops " + progressBar(fetched, total)
+		s.Unlock()
+
+		for _, raindrop := range raindrops {
+			if raindrop.Broken {
+				broken++
+			}
+		}
+		if opts.SkipBroken {
+			var n int
+			raindrops, n = withoutBroken(raindrops)
+			skipped += n
+		}
+		if l := latestUpdate(raindrops); l.After(latest) {
+			latest = l
+		}
+		if opts.Incremental {
+			raindrops = changedSince(raindrops, state.LastUpdate)
+		}
+		if !opts.CreatedAfter.IsZero() {
+			raindrops = createdSince(raindrops, opts.CreatedAfter)
+		}
+		raindrops = dedupe.Filter(raindrops)
+		searchindex.PrepareDocuments(raindrops, titles, paths)
+		searchindex.AddFavicons(raindrops, opts.FaviconService)
+		if opts.NormalizeTags {
+			for i := range raindrops {
+				raindrops[i].Tags = searchindex.NormalizeTags(raindrops[i].Tags)
+			}
+		}
+		counts[collection.ID] += len(raindrops)
+		if opts.HighlightsIndex != "" {
+			highlights = append(highlights, searchindex.HighlightDocuments(raindrops)...)
+		}
+		slog.Info("fetched collection", "collection_id", collection.ID, "title", collection.Title, "documents", len(raindrops))
+
+		if opts.DedupeURL || fetchContent {
+			// copies of a link can be in any collection and pages are only
+			// downloaded once fetching is done, so nothing is sent until every
+			// collection has been fetched
+			fetchedRaindrops = append(fetchedRaindrops, raindrops...)
+			return nil
+		}
+		return sender.Add(ctx, raindrops)
+	})
+	if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		// keep what was fetched before the interrupt, a later run adds the rest
+		s.Stop()
+		flushErr := sender.Flush(context.WithoutCancel(ctx))
+		if flushErr != nil {
+			return flushErr
+		}
+		return fmt.Errorf("interrupted, %d documents were sent before stopping", sender.Sent())
+	}
+	if err != nil {
+		return err
+	}
+
+	var droppedIDs []int
+	if opts.DedupeURL {
+		fetchedRaindrops, droppedIDs = searchindex.DedupeByURL(fetchedRaindrops)
+	}
+	if fetchContent {
+		// downloading in onFetch would hold up every other collection while
+		// a slow page loads
+		s.Suffix = " downloading cached pages"
+		fetchCachedContent(ctx, raindropClient, fetchedRaindrops, opts.Concurrency)
+	}
+	if opts.DedupeURL || fetchContent {
+		err = sender.Add(ctx, fetchedRaindrops)
 		if err != nil {
-			log.Fatalln(err)
+			return err
 		}
-		allRaindrops = append(allRaindrops, raindrops...)
 	}
 
-	s.Suffix = " inserting into meilisearch index"
-	index := client.Index("raindrops")
-	_, err = index.AddDocuments(allRaindrops)
+	err = sender.Flush(ctx)
 	if err != nil {
-		log.Fatalln(err)
+		return err
+	}
+
+	if opts.SkipBroken {
+		slog.Info("skipped broken bookmarks", "count", skipped)
+	}
+	if dedupe.Duplicates > 0 {
+		slog.Info("collapsed duplicate bookmarks", "count", dedupe.Duplicates)
+	}
+	if opts.DedupeURL {
+		slog.Info("collapsed bookmarks with the same link", "count", len(droppedIDs))
+	}
+
+	if opts.DryRun {
+		s.Stop()
+		fmt.Fprintln(w, "dry run, nothing was sent to meilisearch")
+		fmt.Fprintln(w)
+		printCollectionCounts(w, collections, counts)
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "%d documents would be indexed, %d broken, %d duplicates\n", sender.Sent(), broken, dedupe.Duplicates+len(droppedIDs))
+		if opts.HighlightsIndex != "" {
+			fmt.Fprintf(w, "%d highlights would be indexed\n", len(highlights))
+		}
+		return nil
+	}
+
+	s.Suffix = " waiting for meilisearch to process documents"
+	err = sender.Wait(ctx)
+	if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		return errors.New("interrupted, meilisearch keeps processing the documents already sent")
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(droppedIDs) > 0 {
+		// remove copies indexed by earlier runs that didn't collapse links
+		err = searchindex.DeleteDocuments(ctx, client, opts.IndexName, droppedIDs)
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.HighlightsIndex != "" {
+		s.Suffix = " indexing highlights"
+		err = searchindex.IndexHighlights(ctx, client, opts.HighlightsIndex, highlights, opts.BatchSize, opts.Retries)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Only a run over every bookmark can move the incremental state forward,
+	// otherwise changes in the skipped collections or older bookmarks would be
+	// missed next time
+	if len(opts.Collections) == 0 && len(opts.ExcludeCollections) == 0 && opts.CreatedAfter.IsZero() && latest.After(state.LastUpdate) {
+		state.LastUpdate = latest
+		err = saveIndexState(opts.indexID(), state)
+		if err != nil {
+			return err
+		}
 	}
 
 	s.Stop()
-	numDocuments := len(allRaindrops)
-	log.Printf("%d documents indexed", numDocuments)
+	slog.Info("documents indexed", "index", opts.IndexName, "count", sender.Sent())
+	if opts.Summary {
+		fmt.Fprintln(w)
+		printCollectionCounts(w, collections, counts)
+	}
+	return nil
 }
 
-func getRaindropsInCollection(collectionId int, raindropToken string) ([]Raindrop, error) {
-	url := fmt.Sprintf("http://api.raindrop.io/rest/v1/raindrops/%d", collectionId)
+// reindexBookmark fetches a single raindrop and upserts its document, leaving
+// the rest of the index and the incremental state alone
+func reindexBookmark(ctx context.Context, client *meilisearch.Client, raindropClient *raindropio.Client, id int, opts indexOptions) error {
+	raindrop, err := raindropClient.Raindrop(ctx, id)
+	if err != nil {
+		return fmt.Errorf("error getting raindrop %d: %w", id, err)
+	}
 
-	req, err := http.NewRequest("GET", url, nil)
+	collections, err := getCachedCollections(ctx, raindropClient, opts.CollectionCache)
 	if err != nil {
-		return nil, fmt.Errorf("error creating request: %w", err)
+		return err
 	}
 
-	req.Header.Add("Authorization", "Bearer "+raindropToken)
+	raindrops := []raindropio.Raindrop{*raindrop}
+	searchindex.PrepareDocuments(raindrops, searchindex.CollectionTitles(collections), searchindex.CollectionPaths(collections))
+	searchindex.AddFavicons(raindrops, opts.FaviconService)
+	if opts.NormalizeTags {
+		raindrops[0].Tags = searchindex.NormalizeTags(raindrops[0].Tags)
+	}
+	if opts.FetchCache {
+		fetchCachedContent(ctx, raindropClient, raindrops, 1)
+	}
 
-	resp, err := http.DefaultClient.Do(req)
+	err = ensureIndex(ctx, client, opts.IndexName)
 	if err != nil {
-		return nil, fmt.Errorf("error making request: %w", err)
+		return err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	sender := searchindex.NewSender(client, opts.IndexName, 1)
+	sender.Retries = opts.Retries
+	err = sender.Add(ctx, raindrops)
 	if err != nil {
-		return nil, fmt.Errorf("error reading response body: %w", err)
+		return err
 	}
-
-	var raindropsResponse RaindropsResponse
-	err = json.Unmarshal(body, &raindropsResponse)
+	err = sender.Wait(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("error unmarshalling response: %w", err)
+		return err
 	}
 
-	return raindropsResponse.Items, nil
+	slog.Info("bookmark reindexed", "index", opts.IndexName, "id", raindrop.ID, "title", raindrop.Title)
+	return nil
 }
 
-func getCollections(raindropToken string) ([]RaindropCollection, error) {
-	url := "http://api.raindrop.io/rest/v1/collections"
+// ensureIndex creates the index if it doesn't exist yet, pointing at
+// -reset-index when an existing index can't be used
+func ensureIndex(ctx context.Context, client *meilisearch.Client, indexName string) error {
+	err := searchindex.EnsureIndex(ctx, client, indexName)
+	if errors.Is(err, searchindex.ErrWrongPrimaryKey) {
+		return fmt.Errorf("%w, recreate it with -reset-index", err)
+	}
+	return err
+}
 
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("error creating request: %w", err)
+// printCollectionCounts writes an aligned table of how many documents came
+// from each collection, sorted by title
+func printCollectionCounts(w io.Writer, collections []raindropio.Collection, counts map[int]int) {
+	sorted := sortedByTitle(collections)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tCOLLECTION\tDOCUMENTS")
+	for _, collection := range sorted {
+		fmt.Fprintf(tw, "%d\t%s\t%d\n", collection.ID, collection.Title, counts[collection.ID])
+	}
+	tw.Flush()
+}
+
+// selectCollections returns the collections with the given ids, failing if
+// any of the ids doesn't exist
+func selectCollections(collections []raindropio.Collection, ids []int) ([]raindropio.Collection, error) {
+	byID := make(map[int]raindropio.Collection, len(collections))
+	for _, collection := range collections {
+		byID[collection.ID] = collection
+	}
+
+	selected := make([]raindropio.Collection, 0, len(ids))
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		collection, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("collection %d not found, use -collections to list them", id)
+		}
+		if !seen[id] {
+			selected = append(selected, collection)
+			seen[id] = true
+		}
 	}
 
-	req.Header.Add("Authorization", "Bearer "+raindropToken)
+	return selected, nil
+}
+
+// excludeCollections drops the collections whose id or title, ignoring case,
+// is in excluded, warning about entries that matched nothing
+func excludeCollections(collections []raindropio.Collection, excluded []string) []raindropio.Collection {
+	if len(excluded) == 0 {
+		return collections
+	}
+
+	matched := make(map[string]bool, len(excluded))
+	kept := make([]raindropio.Collection, 0, len(collections))
+	for _, collection := range collections {
+		skip := false
+		for _, entry := range excluded {
+			if entry == strconv.Itoa(collection.ID) || strings.EqualFold(entry, collection.Title) {
+				matched[entry] = true
+				skip = true
+			}
+		}
+		if !skip {
+			kept = append(kept, collection)
+		}
+	}
+
+	for _, entry := range excluded {
+		if !matched[entry] {
+			slog.Warn("no collection matches -collection-exclude", "collection", entry)
+		}
+	}
+	return kept
+}
+
+// withoutBroken filters out the raindrops with broken links, returning the
+// remaining raindrops and how many were removed
+func withoutBroken(raindrops []raindropio.Raindrop) ([]raindropio.Raindrop, int) {
+	kept := make([]raindropio.Raindrop, 0, len(raindrops))
+	for _, raindrop := range raindrops {
+		if !raindrop.Broken {
+			kept = append(kept, raindrop)
+		}
+	}
+	return kept, len(raindrops) - len(kept)
+}
+
+// createdSince returns the raindrops created at or after the given time
+func createdSince(raindrops []raindropio.Raindrop, after time.Time) []raindropio.Raindrop {
+	kept := make([]raindropio.Raindrop, 0, len(raindrops))
+	for _, raindrop := range raindrops {
+		if !raindrop.Created.Before(after) {
+			kept = append(kept, raindrop)
+		}
+	}
+	return kept
+}
+
+// listBroken prints the title and link of every bookmark with a broken link
+func listBroken(ctx context.Context, w io.Writer, raindropClient *raindropio.Client, cache collectionCache, concurrency int) error {
+	collections, err := getCachedCollections(ctx, raindropClient, cache)
+	if err != nil {
+		return err
+	}
 
-	resp, err := http.DefaultClient.Do(req)
+	broken := 0
+	err = fetchRaindrops(ctx, raindropClient, collections, concurrency, func(collection raindropio.Collection, raindrops []raindropio.Raindrop) error {
+		for _, raindrop := range raindrops {
+			if raindrop.Broken {
+				fmt.Fprintf(w, "%s\n   %s\n", raindrop.Title, raindrop.Link)
+				broken++
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error making request: %w", err)
+		return err
+	}
+	slog.Info("broken bookmarks", "count", broken)
+	return nil
+}
+
+// fetchRaindrops fetches the raindrops of every collection using up to
+// concurrency workers. onFetch is called with each collection's raindrops as
+// soon as they are fetched, calls are never concurrent. The first error, from
+// fetching or from onFetch, stops the remaining fetches and is returned
+func fetchRaindrops(ctx context.Context, raindropClient *raindropio.Client, collections []raindropio.Collection, concurrency int, onFetch func(raindropio.Collection, []raindropio.Raindrop) error) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+
+	jobs := make(chan raindropio.Collection)
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for collection := range jobs {
+				raindrops, err := raindropClient.RaindropsInCollection(ctx, collection.ID)
+
+				mu.Lock()
+				if err != nil {
+					err = fmt.Errorf("error getting raindrops for '%s': %w", collection.Title, err)
+				} else if firstErr == nil {
+					err = onFetch(collection, raindrops)
+				}
+				if err != nil && firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for _, collection := range collections {
+		select {
+		case jobs <- collection:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// searchOptions controls how searches are run and printed
+type searchOptions struct {
+	// Query holds the filters and the page of hits searched for
+	searchindex.Query
+	// IndexName is the meilisearch index searched
+	IndexName string
+	// Count prints only the estimated number of matches
+	Count bool
+	// Open is the number of the result to open in the browser instead of
+	// printing the results, 0 disables it
+	Open int
+	// Interactive prompts for a result to open after printing the results
+	Interactive bool
+	// Stdin is where the answer to the interactive prompt is read from
+	Stdin io.Reader
+	// Renderer writes the results in the chosen output format
+	Renderer renderer
+}
+
+// sortedByTitle returns a copy of the collections sorted by title
+func sortedByTitle(collections []raindropio.Collection) []raindropio.Collection {
+	sorted := make([]raindropio.Collection, len(collections))
+	copy(sorted, collections)
+	sort.Slice(sorted, func(i, j int) bool {
+		return strings.ToLower(sorted[i].Title) < strings.ToLower(sorted[j].Title)
+	})
+	return sorted
+}
+
+// printCollections writes an aligned table of the collections sorted by title
+func printCollections(w io.Writer, collections []raindropio.Collection) {
+	sorted := sortedByTitle(collections)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTITLE\tCOUNT")
+	for _, collection := range sorted {
+		fmt.Fprintf(tw, "%d\t%s\t%d\n", collection.ID, collection.Title, collection.Count)
+	}
+	tw.Flush()
+}
+
+// sortTags orders tags by descending count, then by name
+func sortTags(tags []raindropio.Tag) {
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Name < tags[j].Name
+	})
+}
+
+// tagsWithPrefix returns the tags starting with prefix, ignoring case, most
+// used first
+func tagsWithPrefix(tags []raindropio.Tag, prefix string) []raindropio.Tag {
+	prefix = strings.ToLower(prefix)
+	var matching []raindropio.Tag
+	for _, tag := range tags {
+		if strings.HasPrefix(strings.ToLower(tag.Name), prefix) {
+			matching = append(matching, tag)
+		}
 	}
-	defer resp.Body.Close()
+	sortTags(matching)
+	return matching
+}
+
+// printTags writes an aligned table of the tags, most used first
+func printTags(w io.Writer, tags []raindropio.Tag) {
+	sortTags(tags)
 
-	body, err := io.ReadAll(resp.Body)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TAG\tCOUNT")
+	for _, tag := range tags {
+		fmt.Fprintf(tw, "%s\t%d\n", tag.Name, tag.Count)
+	}
+	tw.Flush()
+}
+
+// checkHealth confirms meilisearch is up and that the token is accepted,
+// returning the server version
+func checkHealth(client *meilisearch.Client) (string, error) {
+	health, err := client.Health()
 	if err != nil {
-		return nil, fmt.Errorf("error reading response body: %w", err)
+		return "", fmt.Errorf("meilisearch is unreachable: %w", err)
+	}
+	if health.Status != "available" {
+		return "", fmt.Errorf("meilisearch is not available, status is '%s'", health.Status)
 	}
 
-	var collectionResponse RaindropCollectionResponse
-	err = json.Unmarshal(body, &collectionResponse)
+	// the health endpoint is public, the version endpoint needs a valid key
+	version, err := client.GetVersion()
 	if err != nil {
-		return nil, fmt.Errorf("error unmarshalling response: %w", err)
+		return "", fmt.Errorf("meilisearch rejected the request, check DROPSEARCH_MEILISEARCH_TOKEN: %w", err)
 	}
 
-	return collectionResponse.Collections, nil
+	return version.PkgVersion, nil
 }
 
-func searchBookmarks(client *meilisearch.Client, query string) {
-	searchResult, err := client.Index("raindrops").Search(query,
-		&meilisearch.SearchRequest{
-			Limit: 10,
-		})
+// meilisearchPollInterval is how often -wait-for-meili checks whether
+// meilisearch is healthy
+const meilisearchPollInterval = time.Second
+
+// waitForMeilisearch polls the health endpoint until meilisearch is available,
+// giving up once timeout has passed
+func waitForMeilisearch(client *meilisearch.Client, timeout time.Duration) error {
+	slog.Info("waiting for meilisearch", durationAttr("timeout", timeout))
+	deadline := time.Now().Add(timeout)
+	for {
+		health, err := client.Health()
+		if err == nil && health.Status == "available" {
+			return nil
+		}
+		if time.Now().Add(meilisearchPollInterval).After(deadline) {
+			if err != nil {
+				return fmt.Errorf("meilisearch wasn't ready after %s: %w", timeout, err)
+			}
+			return fmt.Errorf("meilisearch wasn't ready after %s, status is '%s'", timeout, health.Status)
+		}
+		time.Sleep(meilisearchPollInterval)
+	}
+}
+
+// showStats prints the document count, indexing status and field
+// distribution of the index
+func showStats(w io.Writer, client *meilisearch.Client, indexName string) error {
+	index, err := client.GetIndex(indexName)
 	if err != nil {
-		log.Fatalln(err)
+		return err
 	}
 
-	hitCountStr := fmt.Sprintf("%d", len(searchResult.Hits))
-	hitCountColor := color.New(color.FgHiYellow).SprintfFunc()
-	queryColor := color.New(color.FgHiCyan).SprintFunc()
-	log.Println("found", hitCountColor(hitCountStr), "hits for", queryColor(query))
+	stats, err := index.GetStats()
+	if err != nil {
+		return err
+	}
+
+	printStats(w, index.UpdatedAt, stats)
+	return nil
+}
+
+// printStats writes the index stats, with the field distribution as an
+// aligned table sorted by field name
+func printStats(w io.Writer, updatedAt time.Time, stats *meilisearch.StatsIndex) {
+	fmt.Fprintf(w, "Documents:    %d\n", stats.NumberOfDocuments)
+	fmt.Fprintf(w, "Indexing:     %t\n", stats.IsIndexing)
+	fmt.Fprintf(w, "Last updated: %s\n", updatedAt.Local().Format("2006-01-02 15:04:05"))
+
+	if len(stats.FieldDistribution) == 0 {
+		return
+	}
+
+	fields := make([]string, 0, len(stats.FieldDistribution))
+	for field := range stats.FieldDistribution {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
 
-	titleColor := color.New(color.FgGreen).SprintFunc()
-	linkColor := color.New(color.FgBlue).SprintFunc()
-	infoColor := color.New(color.Faint).SprintFunc()
-	tagColor := color.New(color.FgYellow).SprintFunc()
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FIELD\tDOCUMENTS")
+	for _, field := range fields {
+		fmt.Fprintf(tw, "%s\t%d\n", field, stats.FieldDistribution[field])
+	}
+	tw.Flush()
+}
 
-	for i, hit := range searchResult.Hits {
-		hitBytes, err := json.Marshal(hit)
+func searchBookmarks(w io.Writer, client *meilisearch.Client, query string, opts searchOptions) error {
+	index := client.Index(opts.IndexName)
+	if opts.Count {
+		total, err := searchindex.Count(index, query, opts.Query)
+		if searchindex.IsIndexNotFound(err) {
+			return fmt.Errorf("index %q doesn't exist yet, run dropsearch -i to index your bookmarks", opts.IndexName)
+		}
 		if err != nil {
-			log.Println("error marshalling bytes to json:", err)
-			continue
+			return err
+		}
+		if total == 0 && searchindex.IndexIsEmpty(index) {
+			slog.Warn("the index is empty, run dropsearch -i to index your bookmarks", "index", opts.IndexName)
 		}
+		return printCount(w, total)
+	}
 
-		var raindrop Raindrop
-		err = json.Unmarshal(hitBytes, &raindrop)
-		if err != nil {
-			log.Fatalln("enmarshal error:", err)
+	result, err := searchindex.Search(index, query, opts.Query)
+	if searchindex.IsIndexNotFound(err) {
+		return fmt.Errorf("index %q doesn't exist yet, run dropsearch -i to index your bookmarks", opts.IndexName)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(result.Hits) == 0 && searchindex.IndexIsEmpty(index) {
+		slog.Warn("the index is empty, run dropsearch -i to index your bookmarks", "index", opts.IndexName)
+	}
+
+	return showHits(w, query, result.Hits, result.EstimatedTotalHits, opts)
+}
+
+// printCount writes the number of matching bookmarks for -count
+func printCount(w io.Writer, total int64) error {
+	fmt.Fprintln(w, total)
+	if total == 0 {
+		return errNoHits
+	}
+	return nil
+}
+
+// showHits opens, renders or lets the user pick from the hits of a search,
+// depending on the options
+func showHits(w io.Writer, query string, hits []searchindex.Hit, estimatedTotal int64, opts searchOptions) error {
+	if opts.Open > 0 {
+		if len(hits) == 0 {
+			slog.Warn("no results to open")
+			return errNoHits
 		}
+		return openResult(w, hits, opts.Offset, opts.Open)
+	}
+
+	err := opts.Renderer.render(w, searchResults{
+		Query:          query,
+		Hits:           hits,
+		Offset:         opts.Offset,
+		Limit:          opts.Limit,
+		EstimatedTotal: estimatedTotal,
+	})
+	if err != nil {
+		return fmt.Errorf("error writing results: %w", err)
+	}
+	if len(hits) == 0 {
+		return errNoHits
+	}
 
-		fmt.Printf("%d. %s\n", i+1, titleColor(raindrop.Title))
-		fmt.Printf("   Link: %s\n", linkColor(raindrop.Link))
-		if raindrop.Excerpt != "" {
-			fmt.Printf("   Excerpt: %s\n", raindrop.Excerpt)
+	if opts.Interactive {
+		hit, err := selectHit(opts.Stdin, w, hits, opts.Offset)
+		if errors.Is(err, errNoSelection) {
+			return nil
 		}
-		fmt.Printf("   Domain: %s, Created: %s\n", infoColor(raindrop.Domain), infoColor(raindrop.Created.Format("2006-01-02")))
-		if len(raindrop.Tags) > 0 {
-			fmt.Printf("   Tags: %s\n", tagColor(strings.Join(raindrop.Tags, ", ")))
+		if err != nil {
+			return err
 		}
-		fmt.Println()
+
+		slog.Info("opening result", "title", hit.Title, "link", hit.Link)
+		err = openURL(hit.Link)
+		if err != nil {
+			return fmt.Errorf("error opening link: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// openResult opens the link of result number n, numbered the same way as the
+// printed results
+func openResult(w io.Writer, hits []searchindex.Hit, offset int64, n int) error {
+	if len(hits) == 0 {
+		return errors.New("no results to open")
+	}
+
+	i := int64(n) - offset - 1
+	if i < 0 || i >= int64(len(hits)) {
+		return usageErrorf("result %d is out of range, results are numbered %d to %d", n, offset+1, offset+int64(len(hits)))
+	}
+
+	raindrop := hits[i].Raindrop
+	fmt.Fprintf(w, "opening %s (%s)\n", raindrop.Title, raindrop.Link)
+	err := openURL(raindrop.Link)
+	if err != nil {
+		return fmt.Errorf("error opening link: %w", err)
 	}
 
+	return nil
 }
@@ -0,0 +1,757 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/fatih/color"
+	"github.com/meilisearch/meilisearch-go"
+	"github.com/zpeters/dropsearch/raindropio"
+	"github.com/zpeters/dropsearch/searchindex"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// isolate keeps run away from the real config file, state and environment
+func isolate(t *testing.T) {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	for _, name := range []string{"DROPSEARCH_RAINDROP_TOKEN", "DROPSEARCH_MEILISEARCH_TOKEN", "DROPSEARCH_MEILISEARCH_HOST", "DROPSEARCH_INDEX", "DROPSEARCH_PROFILE"} {
+		t.Setenv(name, "")
+	}
+}
+
+// fakeSearch serves meilisearch searches with the given hits, recording the
+// body of every request
+func fakeSearch(t *testing.T, hits string, total int) (*httptest.Server, *[]map[string]interface{}) {
+	t.Helper()
+	var requests []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/indexes/raindrops/search":
+			var request map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&request)
+			requests = append(requests, request)
+			fmt.Fprintf(w, `{"hits": %s, "estimatedTotalHits": %d}`, hits, total)
+		case "/indexes/raindrops/stats":
+			fmt.Fprint(w, `{"numberOfDocuments": 2}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func runWith(args ...string) (int, string, string) {
+	return runWithInput("", args...)
+}
+
+func runWithInput(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRunVersion(t *testing.T) {
+	isolate(t)
+	code, stdout, _ := runWith("-version")
+	if code != exitOK || !strings.HasPrefix(stdout, "dropsearch ") {
+		t.Errorf("-version exited %d with %q", code, stdout)
+	}
+}
+
+func TestRunRestoresGlobals(t *testing.T) {
+	isolate(t)
+	noColor, logger := color.NoColor, slog.Default()
+	t.Cleanup(func() { color.NoColor = noColor })
+	color.NoColor = false
+
+	code, _, stderr := runWith("-no-color", "-log-format", "json", "-quiet", "-legend")
+	if code != exitOK {
+		t.Fatalf("-legend exited %d: %s", code, stderr)
+	}
+	if color.NoColor {
+		t.Error("-no-color is still set after run returned")
+	}
+	if slog.Default() != logger {
+		t.Error("the logger set up by run is still the default")
+	}
+}
+
+func TestRunUsageErrors(t *testing.T) {
+	isolate(t)
+	tests := [][]string{
+		{"-no-such-flag"},
+		{"-limit", "-1", "go"},
+		{"-sort", "title", "go"},
+		{"-match", "some", "go"},
+		{"-type", "podcast", "go"},
+		{"-after", "yesterday", "go"},
+		{"-min-score", "2", "go"},
+		{"-highlights", "-count", "go"},
+		{"-completion", "tcsh"},
+		{"-all-indexes", "-sort", "created:desc", "go"},
+	}
+	for _, args := range tests {
+		// no tokens are set, bad flags have to be reported before that matters
+		code, _, stderr := runWith(args...)
+		if code != exitUsage {
+			t.Errorf("%v exited %d, want %d: %s", args, code, exitUsage, stderr)
+		}
+	}
+}
+
+func TestRunShowsUsage(t *testing.T) {
+	isolate(t)
+	code, _, stderr := runWith()
+	if code != exitUsage {
+		t.Errorf("exited %d without a query, want %d", code, exitUsage)
+	}
+	if !strings.HasPrefix(stderr, "Usage: dropsearch [flags] [search query]\n") {
+		t.Errorf("stderr starts %q, want the usage line", stderr)
+	}
+	for _, flag := range []string{"-i\tIndex bookmarks", "-limit value", "-format string"} {
+		if !strings.Contains(stderr, "  "+flag) {
+			t.Errorf("usage is missing %q, want every flag listed", flag)
+		}
+	}
+}
+
+func TestRunMissingToken(t *testing.T) {
+	isolate(t)
+	code, _, stderr := runWith("go")
+	if code != exitError || !strings.Contains(stderr, "DROPSEARCH_MEILISEARCH_TOKEN") {
+		t.Errorf("exited %d with %q, want an error naming the token", code, stderr)
+	}
+}
+
+func TestRunSearch(t *testing.T) {
+	isolate(t)
+	server, requests := fakeSearch(t, `[{"_id": 1, "title": "Effective Go", "link": "https://go.dev/doc/effective_go", "important": true}]`, 1)
+	t.Setenv("DROPSEARCH_MEILISEARCH_TOKEN", "secret")
+
+	code, stdout, stderr := runWith("-host", server.URL, "-format", "json", "-tag", "go", "-important", "effective go")
+	if code != exitOK {
+		t.Fatalf("exited %d: %s", code, stderr)
+	}
+
+	var raindrops []struct {
+		ID        int    `json:"_id"`
+		Title     string `json:"title"`
+		Important bool   `json:"important"`
+	}
+	err := json.Unmarshal([]byte(stdout), &raindrops)
+	if err != nil {
+		t.Fatalf("output isn't JSON: %v\n%s", err, stdout)
+	}
+	if len(raindrops) != 1 || raindrops[0].Title != "Effective Go" || !raindrops[0].Important {
+		t.Errorf("got %+v, want the hit", raindrops)
+	}
+
+	request := (*requests)[0]
+	if request["q"] != "effective go" {
+		t.Errorf("searched for %v, want the arguments joined", request["q"])
+	}
+	if request["filter"] != `tags = "go" AND important = true` {
+		t.Errorf("filter = %v, want the tag and important filters", request["filter"])
+	}
+}
+
+func TestRunCount(t *testing.T) {
+	isolate(t)
+	server, _ := fakeSearch(t, `[{"_id": 1}]`, 7)
+	t.Setenv("DROPSEARCH_MEILISEARCH_TOKEN", "secret")
+
+	code, stdout, stderr := runWith("-host", server.URL, "-count", "-q", "go")
+	if code != exitOK || strings.TrimSpace(stdout) != "7" {
+		t.Errorf("exited %d with %q, want 7: %s", code, stdout, stderr)
+	}
+}
+
+func TestRunNoHits(t *testing.T) {
+	isolate(t)
+	server, _ := fakeSearch(t, `[]`, 0)
+	t.Setenv("DROPSEARCH_MEILISEARCH_TOKEN", "secret")
+
+	code, _, stderr := runWith("-host", server.URL, "nothing matches")
+	if code != exitNoHits {
+		t.Errorf("exited %d, want %d: %s", code, exitNoHits, stderr)
+	}
+}
+
+func TestRunNoColor(t *testing.T) {
+	isolate(t)
+	noColor := color.NoColor
+	t.Cleanup(func() { color.NoColor = noColor })
+
+	tests := []struct {
+		name    string
+		env     string
+		args    []string
+		colored bool
+	}{
+		{"colored", "", []string{"-legend"}, true},
+		{"flag", "", []string{"-no-color", "-legend"}, false},
+		{"environment", "1", []string{"-legend"}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("NO_COLOR", test.env)
+			color.NoColor = false
+			_, stdout, _ := runWith(test.args...)
+			if colored := strings.Contains(stdout, "\x1b["); colored != test.colored {
+				t.Errorf("colored = %t, want %t: %q", colored, test.colored, stdout)
+			}
+		})
+	}
+}
+
+// newRaindropClient returns a Raindrop client for a test server running
+// handler, without retries
+func newRaindropClient(t *testing.T, handler http.HandlerFunc) *raindropio.Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	client := raindropio.NewClient("test-token")
+	client.BaseURL = server.URL
+	client.Retries = 0
+	return client
+}
+
+// collectionHandler serves two raindrops for every collection, numbered from
+// ten times the collection id. Collections with lower ids answer last
+func collectionHandler(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/rest/v1/raindrops/"))
+	if r.URL.Query().Get("page") != "0" {
+		fmt.Fprint(w, `{"items": []}`)
+		return
+	}
+	time.Sleep(time.Duration(5-id) * 10 * time.Millisecond)
+	fmt.Fprintf(w, `{"count": 2, "items": [{"_id": %d}, {"_id": %d}]}`, id*10, id*10+1)
+}
+
+func TestFetchRaindropsCollectsEveryCollection(t *testing.T) {
+	client := newRaindropClient(t, collectionHandler)
+	collections := []raindropio.Collection{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}}
+
+	var ids []int
+	err := fetchRaindrops(context.Background(), client, collections, 4, func(collection raindropio.Collection, raindrops []raindropio.Raindrop) error {
+		for _, raindrop := range raindrops {
+			ids = append(ids, raindrop.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Ints(ids)
+	if fmt.Sprint(ids) != "[10 11 20 21 30 31 40 41]" {
+		t.Errorf("got raindrops %v, want both of every collection", ids)
+	}
+}
+
+func TestFetchRaindropsStopsWhenCancelled(t *testing.T) {
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	client := newRaindropClient(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+
+	collections := make([]raindropio.Collection, 50)
+	for i := range collections {
+		collections[i] = raindropio.Collection{ID: i + 1}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	err := fetchRaindrops(ctx, client, collections, 2, func(raindropio.Collection, []raindropio.Raindrop) error {
+		t.Error("fetched a collection after cancelling")
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("fetchRaindrops() = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("fetchRaindrops() took %v to stop, want it to stop promptly", elapsed)
+	}
+}
+
+func TestReindexBookmark(t *testing.T) {
+	raindropClient := newRaindropClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/v1/raindrop/7":
+			fmt.Fprint(w, `{"result": true, "item": {"_id": 7, "title": "Effective Go", "link": "https://go.dev/doc/effective_go", "tags": ["Go"], "collection": {"$id": 3}}}`)
+		case "/rest/v1/collections":
+			fmt.Fprint(w, `{"result": true, "items": [{"_id": 3, "title": "Reading"}]}`)
+		default:
+			fmt.Fprint(w, `{"result": true, "items": []}`)
+		}
+	})
+
+	var sent []map[string]any
+	client := newMeilisearchClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/indexes/raindrops":
+			fmt.Fprint(w, `{"uid": "raindrops", "primaryKey": "_id"}`)
+		case r.URL.Path == "/indexes/raindrops/documents" && r.Method == http.MethodPost:
+			json.NewDecoder(r.Body).Decode(&sent)
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprint(w, `{"taskUid": 1, "status": "enqueued"}`)
+		case r.URL.Path == "/tasks/1":
+			fmt.Fprint(w, `{"uid": 1, "status": "succeeded"}`)
+		default:
+			t.Errorf("unexpected meilisearch request %s %s", r.Method, r.URL.Path)
+		}
+	})
+
+	err := reindexBookmark(context.Background(), client, raindropClient, 7, indexOptions{IndexName: "raindrops", NormalizeTags: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 1 {
+		t.Fatalf("sent %d documents, want only the reindexed bookmark", len(sent))
+	}
+	document := sent[0]
+	if document["_id"] != float64(7) || document["collection_title"] != "Reading" || fmt.Sprint(document["tags"]) != "[go]" {
+		t.Errorf("sent %v, want bookmark 7 with its collection title and normalized tags", document)
+	}
+}
+
+func TestCreateOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results", "go", "hits.md")
+	for _, content := range []string{"an older, longer result", "new"} {
+		f, err := createOutput(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprint(f, content)
+		f.Close()
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "new" {
+		t.Errorf("output holds %q, want the missing directories created and the old file truncated", data)
+	}
+}
+
+func TestRunOutput(t *testing.T) {
+	isolate(t)
+	server, _ := fakeSearch(t, `[{"_id": 1, "title": "Effective Go", "link": "https://go.dev/doc/effective_go"}]`, 1)
+	t.Setenv("DROPSEARCH_MEILISEARCH_TOKEN", "secret")
+	path := filepath.Join(t.TempDir(), "hits.json")
+
+	code, stdout, _ := runWith("-host", server.URL, "-format", "json", "-output", path, "go")
+	if code != exitOK || stdout != "" {
+		t.Errorf("exited %d writing %q to stdout, want everything in the file", code, stdout)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"title": "Effective Go"`) {
+		t.Errorf("output file holds %s, want the results", data)
+	}
+}
+
+func TestRunOffset(t *testing.T) {
+	isolate(t)
+	server, requests := fakeSearch(t, `[{"_id": 1, "title": "Effective Go"}]`, 30)
+	t.Setenv("DROPSEARCH_MEILISEARCH_TOKEN", "secret")
+
+	code, stdout, stderr := runWith("-host", server.URL, "-no-color", "-offset", "20", "-limit", "5", "go")
+	if code != exitOK {
+		t.Fatalf("exited %d: %s", code, stderr)
+	}
+	request := (*requests)[0]
+	if request["offset"] != 20.0 || request["limit"] != 5.0 {
+		t.Errorf("requested offset %v and limit %v, want 20 and 5", request["offset"], request["limit"])
+	}
+	if !strings.HasPrefix(stdout, "21. Effective Go") {
+		t.Errorf("results aren't numbered from the offset:\n%s", stdout)
+	}
+}
+
+func TestPrintCollections(t *testing.T) {
+	var b bytes.Buffer
+	printCollections(&b, []raindropio.Collection{
+		{ID: 2, Title: "reading", Count: 12},
+		{ID: 1, Title: "Dev", Count: 3},
+	})
+
+	want := "ID  TITLE    COUNT\n" +
+		"1   Dev      3\n" +
+		"2   reading  12\n"
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestPrintTags(t *testing.T) {
+	var b bytes.Buffer
+	printTags(&b, []raindropio.Tag{
+		{Name: "web", Count: 2},
+		{Name: "go", Count: 5},
+		{Name: "css", Count: 2},
+	})
+
+	want := "TAG  COUNT\n" +
+		"go   5\n" +
+		"css  2\n" +
+		"web  2\n"
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant the most used first, then by name\n%s", b.String(), want)
+	}
+}
+
+func TestTagsWithPrefix(t *testing.T) {
+	tags := []raindropio.Tag{
+		{Name: "golang", Count: 1},
+		{Name: "web", Count: 9},
+		{Name: "Go", Count: 5},
+		{Name: "gopher", Count: 5},
+		{Name: "algo", Count: 3},
+	}
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"go", []string{"Go", "gopher", "golang"}},
+		{"GO", []string{"Go", "gopher", "golang"}},
+		{"goph", []string{"gopher"}},
+		{"css", nil},
+		{"", []string{"web", "Go", "gopher", "algo", "golang"}},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, tag := range tagsWithPrefix(tags, test.prefix) {
+			got = append(got, tag.Name)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("tagsWithPrefix(%q) = %v, want %v", test.prefix, got, test.want)
+		}
+	}
+}
+
+// stubBrowser replaces runCommand for the rest of the test, returning the
+// links that would have been opened
+func stubBrowser(t *testing.T) *[]string {
+	t.Helper()
+	var opened []string
+	previous := runCommand
+	runCommand = func(name string, args ...string) error {
+		opened = append(opened, args[len(args)-1])
+		return nil
+	}
+	t.Cleanup(func() { runCommand = previous })
+	return &opened
+}
+
+func TestOpenResult(t *testing.T) {
+	opened := stubBrowser(t)
+	hits := []searchindex.Hit{
+		{Raindrop: raindropio.Raindrop{Title: "First", Link: "https://example.com/1"}},
+		{Raindrop: raindropio.Raindrop{Title: "Second", Link: "https://example.com/2"}},
+	}
+
+	var b bytes.Buffer
+	err := openResult(&b, hits, 10, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(*opened) != "[https://example.com/2]" {
+		t.Errorf("opened %v, want result 12, the second on a page starting at 11", *opened)
+	}
+
+	err = openResult(&b, hits, 10, 3)
+	if exitCode(err) != exitUsage {
+		t.Errorf("opening a result off the page returned %v, want a usage error", err)
+	}
+}
+
+func TestRunFirst(t *testing.T) {
+	isolate(t)
+	opened := stubBrowser(t)
+	server, _ := fakeSearch(t, `[{"_id": 1, "title": "Effective Go", "link": "https://go.dev/doc/effective_go"}, {"_id": 2, "title": "Go blog", "link": "https://go.dev/blog"}]`, 30)
+	t.Setenv("DROPSEARCH_MEILISEARCH_TOKEN", "secret")
+
+	code, _, stderr := runWith("-host", server.URL, "-offset", "10", "-first", "go")
+	if code != exitOK {
+		t.Fatalf("exited %d: %s", code, stderr)
+	}
+	if fmt.Sprint(*opened) != "[https://go.dev/doc/effective_go]" {
+		t.Errorf("opened %v, want the top result of the page", *opened)
+	}
+
+	empty, _ := fakeSearch(t, `[]`, 0)
+	code, _, _ = runWith("-host", empty.URL, "-first", "go")
+	if code != exitNoHits || len(*opened) != 1 {
+		t.Errorf("-first without results exited %d and opened %v, want no hits and nothing opened", code, *opened)
+	}
+}
+
+func TestRunMatchingStrategy(t *testing.T) {
+	isolate(t)
+	server, requests := fakeSearch(t, `[{"_id": 1, "title": "Effective Go"}]`, 1)
+	t.Setenv("DROPSEARCH_MEILISEARCH_TOKEN", "secret")
+
+	runWith("-host", server.URL, "-match", "all", "effective go")
+	runWith("-host", server.URL, "effective go")
+	if got := (*requests)[0]["matchingStrategy"]; got != "all" {
+		t.Errorf("-match all sent matchingStrategy %v, want all", got)
+	}
+	if got, ok := (*requests)[1]["matchingStrategy"]; ok {
+		t.Errorf("without -match sent matchingStrategy %v, want meilisearch's default", got)
+	}
+}
+
+func TestWithoutBroken(t *testing.T) {
+	raindrops := []raindropio.Raindrop{{ID: 1}, {ID: 2, Broken: true}, {ID: 3}}
+
+	kept, skipped := withoutBroken(raindrops)
+
+	if len(kept) != 2 || kept[0].ID != 1 || kept[1].ID != 3 || skipped != 1 {
+		t.Errorf("kept %+v and skipped %d, want 1 and 3 kept and 1 skipped", kept, skipped)
+	}
+}
+
+func TestSelectCollections(t *testing.T) {
+	collections := []raindropio.Collection{{ID: 1, Title: "Dev"}, {ID: 2, Title: "Go"}, {ID: 3, Title: "Reading"}}
+
+	selected, err := selectCollections(collections, []int{3, 1, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(selected) != 2 || selected[0].ID != 3 || selected[1].ID != 1 {
+		t.Errorf("selected %+v, want collections 3 and 1 once each", selected)
+	}
+
+	_, err = selectCollections(collections, []int{1, 42})
+	if err == nil || !strings.Contains(err.Error(), "collection 42 not found") {
+		t.Errorf("got %v, want an error naming the missing collection", err)
+	}
+}
+
+func TestExcludeCollections(t *testing.T) {
+	logs := captureLogs(t, "text", false)
+	collections := []raindropio.Collection{{ID: 1, Title: "Reading"}, {ID: 2, Title: "Archive"}, {ID: 3, Title: "Go"}}
+
+	var ids []int
+	for _, collection := range excludeCollections(collections, []string{"archive", "3", "Recipes"}) {
+		ids = append(ids, collection.ID)
+	}
+	if fmt.Sprint(ids) != "[1]" {
+		t.Errorf("kept %v, want only 1 once Archive by title and 3 by id are excluded", ids)
+	}
+	if !strings.Contains(logs.String(), "no collection matches -collection-exclude") || !strings.Contains(logs.String(), "collection=Recipes") {
+		t.Errorf("logged %q, want a warning about Recipes matching nothing", logs.String())
+	}
+	if got := excludeCollections(collections, nil); len(got) != 3 {
+		t.Errorf("kept %d without exclusions, want all 3", len(got))
+	}
+}
+
+func TestPrintStats(t *testing.T) {
+	var b bytes.Buffer
+	printStats(&b, time.Date(2024, 3, 5, 10, 30, 0, 0, time.Local), &meilisearch.StatsIndex{
+		NumberOfDocuments: 42,
+		FieldDistribution: map[string]int64{"title": 42, "note": 7},
+	})
+
+	want := "Documents:    42\n" +
+		"Indexing:     false\n" +
+		"Last updated: 2024-03-05 10:30:00\n" +
+		"\n" +
+		"FIELD  DOCUMENTS\n" +
+		"note   7\n" +
+		"title  42\n"
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+// newMeilisearchClient returns a meilisearch client for a test server running
+// handler
+func newMeilisearchClient(t *testing.T, handler http.HandlerFunc) *meilisearch.Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return meilisearch.NewClient(meilisearch.ClientConfig{Host: server.URL})
+}
+
+func TestCheckHealth(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  string
+		version int
+		want    string
+	}{
+		{"healthy", "available", http.StatusOK, ""},
+		{"bad token", "available", http.StatusUnauthorized, "check DROPSEARCH_MEILISEARCH_TOKEN"},
+		{"unavailable", "starting", http.StatusOK, "status is 'starting'"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := newMeilisearchClient(t, func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/health":
+					fmt.Fprintf(w, `{"status": %q}`, test.status)
+				case "/version":
+					w.WriteHeader(test.version)
+					if test.version != http.StatusOK {
+						fmt.Fprint(w, `{"message": "The provided API key is invalid.", "code": "invalid_api_key"}`)
+						return
+					}
+					fmt.Fprint(w, `{"pkgVersion": "1.5.0"}`)
+				}
+			})
+
+			version, err := checkHealth(client)
+			if test.want == "" {
+				if err != nil || version != "1.5.0" {
+					t.Errorf("got %q, %v, want version 1.5.0", version, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got %v, want an error containing %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestRunCustomIndex(t *testing.T) {
+	isolate(t)
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		fmt.Fprint(w, `{"hits": [{"_id": 1, "title": "Effective Go"}], "estimatedTotalHits": 1}`)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("DROPSEARCH_MEILISEARCH_TOKEN", "secret")
+	t.Setenv("DROPSEARCH_INDEX", "from-env")
+
+	runWith("-host", server.URL, "go")
+	runWith("-host", server.URL, "-index", "work", "go")
+
+	if fmt.Sprint(paths) != "[/indexes/from-env/search /indexes/work/search]" {
+		t.Errorf("searched %v, want the DROPSEARCH_INDEX index and then the -index one", paths)
+	}
+}
+
+func TestRunQueryFromStdin(t *testing.T) {
+	isolate(t)
+	server, requests := fakeSearch(t, `[{"_id": 1, "title": "Effective Go"}]`, 1)
+	t.Setenv("DROPSEARCH_MEILISEARCH_TOKEN", "secret")
+
+	code, _, stderr := runWithInput("effective\n  go\n", "-host", server.URL)
+	if code != exitOK {
+		t.Fatalf("exited %d: %s", code, stderr)
+	}
+	if q := (*requests)[0]["q"]; q != "effective go" {
+		t.Errorf("searched for %q, want the piped query on one line", q)
+	}
+}
+
+func TestRunWarnsAboutEmptyIndex(t *testing.T) {
+	isolate(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/indexes/raindrops/search":
+			fmt.Fprint(w, `{"hits": [], "estimatedTotalHits": 0}`)
+		case "/indexes/raindrops/stats":
+			fmt.Fprint(w, `{"numberOfDocuments": 0}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("DROPSEARCH_MEILISEARCH_TOKEN", "secret")
+
+	code, _, stderr := runWith("-host", server.URL, "go")
+	if code != exitNoHits || !strings.Contains(stderr, "the index is empty, run dropsearch -i") {
+		t.Errorf("exited %d with %q, want no hits and a warning about the empty index", code, stderr)
+	}
+
+	_, _, stderr = runWith("-host", server.URL, "-count", "go")
+	if !strings.Contains(stderr, "the index is empty") {
+		t.Errorf("-count logged %q, want the same warning", stderr)
+	}
+}
+
+func TestCreatedSince(t *testing.T) {
+	after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	raindrops := []raindropio.Raindrop{
+		{ID: 1, Created: after.Add(-time.Second)},
+		{ID: 2, Created: after},
+		{ID: 3, Created: after.AddDate(0, 1, 0)},
+	}
+
+	var ids []int
+	for _, raindrop := range createdSince(raindrops, after) {
+		ids = append(ids, raindrop.ID)
+	}
+	if fmt.Sprint(ids) != "[2 3]" {
+		t.Errorf("kept %v, want [2 3] created at or after the date", ids)
+	}
+}
+
+func TestWaitForMeilisearch(t *testing.T) {
+	checks := 0
+	client := newMeilisearchClient(t, func(w http.ResponseWriter, r *http.Request) {
+		checks++
+		if checks < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"status": "starting"}`)
+			return
+		}
+		fmt.Fprint(w, `{"status": "available"}`)
+	})
+
+	err := waitForMeilisearch(client, 5*time.Second)
+	if err != nil || checks != 2 {
+		t.Errorf("got %v after %d checks, want meilisearch ready on the second", err, checks)
+	}
+
+	down := newMeilisearchClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status": "starting"}`)
+	})
+	err = waitForMeilisearch(down, 0)
+	if err == nil || !strings.Contains(err.Error(), "meilisearch wasn't ready after 0s, status is 'starting'") {
+		t.Errorf("got %v, want a timeout naming the last status", err)
+	}
+}
+
+func TestPrintCollectionCounts(t *testing.T) {
+	collections := []raindropio.Collection{{ID: 12, Title: "Reading"}, {ID: 3, Title: "Go"}, {ID: 40, Title: "Archive"}}
+	counts := map[int]int{12: 7, 3: 120}
+
+	var b bytes.Buffer
+	printCollectionCounts(&b, collections, counts)
+
+	want := "ID  COLLECTION  DOCUMENTS\n" +
+		"40  Archive     0\n" +
+		"3   Go          120\n" +
+		"12  Reading     7\n"
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"github.com/meilisearch/meilisearch-go"
+	"github.com/zpeters/dropsearch/searchindex"
+	"io"
+	"log/slog"
+	"sort"
+)
+
+// indexTarget is one of the indexes searched by -all-indexes
+type indexTarget struct {
+	// Profile is the config file profile the index belongs to
+	Profile string
+	Client  *meilisearch.Client
+	Index   string
+}
+
+// searchAllIndexes runs the query against every target and merges the hits
+// by ranking score, labelling each with the profile it came from since
+// profiles can share an index name. Indexes that don't exist yet are skipped
+// with a warning
+func searchAllIndexes(w io.Writer, targets []indexTarget, q string, opts searchOptions) error {
+	var (
+		hits  []searchindex.Hit
+		total int64
+	)
+	// the page of merged results can draw on any index, so each one has to
+	// return everything up to the end of the page
+	query := opts.Query
+	query.RankingScore = true
+	query.Offset = 0
+	if opts.Limit > 0 {
+		query.Limit = opts.Offset + opts.Limit
+	}
+
+	for _, target := range targets {
+		index := target.Client.Index(target.Index)
+		var (
+			result *searchindex.Result
+			err    error
+		)
+		if opts.Count {
+			result = &searchindex.Result{}
+			result.EstimatedTotalHits, err = searchindex.Count(index, q, query)
+		} else {
+			result, err = searchindex.Search(index, q, query)
+		}
+		if searchindex.IsIndexNotFound(err) {
+			slog.Warn("skipping index that doesn't exist yet", "profile", target.Profile, "index", target.Index)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error searching profile %s: %w", target.Profile, err)
+		}
+
+		total += result.EstimatedTotalHits
+		for i := range result.Hits {
+			result.Hits[i].Source = target.Profile
+		}
+		hits = append(hits, result.Hits...)
+	}
+
+	if opts.Count {
+		return printCount(w, total)
+	}
+
+	return showHits(w, q, mergeByScore(hits, opts.Offset, opts.Limit), total, opts)
+}
+
+// mergeByScore orders hits from several indexes by ranking score, best first,
+// and returns the page of limit hits after offset. A limit of 0 keeps every
+// hit after offset
+func mergeByScore(hits []searchindex.Hit, offset int64, limit int64) []searchindex.Hit {
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].RankingScore > hits[j].RankingScore
+	})
+
+	if offset >= int64(len(hits)) {
+		return []searchindex.Hit{}
+	}
+	hits = hits[offset:]
+	if limit > 0 && int64(len(hits)) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/meilisearch/meilisearch-go"
+	"github.com/zpeters/dropsearch/searchindex"
+	"net/http"
+	"testing"
+)
+
+// rankedSearch serves searches of the raindrops index with hits titled and
+// scored as given, in that order
+func rankedSearch(t *testing.T, scores map[string]float64, titles ...string) *meilisearch.Client {
+	return newMeilisearchClient(t, func(w http.ResponseWriter, r *http.Request) {
+		hits := make([]map[string]interface{}, 0, len(titles))
+		for i, title := range titles {
+			hits = append(hits, map[string]interface{}{"_id": i + 1, "title": title, "_rankingScore": scores[title]})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"hits": hits, "estimatedTotalHits": len(hits)})
+	})
+}
+
+func TestSearchAllIndexes(t *testing.T) {
+	scores := map[string]float64{"Effective Go": 0.9, "Go blog": 0.5, "Go at work": 0.7, "Go tour": 0.3}
+	missing := newMeilisearchClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"message": "Index raindrops not found.", "code": "index_not_found", "type": "invalid_request"}`)
+	})
+	// every profile uses the same index name, only the profile tells them apart
+	targets := []indexTarget{
+		{Profile: "home", Client: rankedSearch(t, scores, "Effective Go", "Go blog"), Index: "raindrops"},
+		{Profile: "new", Client: missing, Index: "raindrops"},
+		{Profile: "work", Client: rankedSearch(t, scores, "Go at work", "Go tour"), Index: "raindrops"},
+	}
+
+	var b bytes.Buffer
+	opts := searchOptions{Query: searchindex.Query{Limit: 2, Offset: 1}, Renderer: jsonlRenderer{}}
+	err := searchAllIndexes(&b, targets, "go", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	decoder := json.NewDecoder(&b)
+	for decoder.More() {
+		var raindrop sourcedRaindrop
+		err := decoder.Decode(&raindrop)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, raindrop.Title+" from "+raindrop.Source)
+	}
+	if fmt.Sprint(got) != "[Go at work from work Go blog from home]" {
+		t.Errorf("got %q, want the second and third best hits labelled with their profile", got)
+	}
+}
+
+func TestMergeByScore(t *testing.T) {
+	hits := []searchindex.Hit{
+		{RankingScore: 0.2, Source: "home"},
+		{RankingScore: 0.8, Source: "work"},
+		{RankingScore: 0.5, Source: "home"},
+		{RankingScore: 0.8, Source: "home"},
+	}
+
+	var sources []string
+	for _, hit := range mergeByScore(hits, 0, 0) {
+		sources = append(sources, fmt.Sprintf("%s %.1f", hit.Source, hit.RankingScore))
+	}
+	if fmt.Sprint(sources) != "[work 0.8 home 0.8 home 0.5 home 0.2]" {
+		t.Errorf("merged %v, want best first keeping the order of equal scores", sources)
+	}
+	if page := mergeByScore(hits, 1, 2); len(page) != 2 || page[0].Source != "home" || page[1].RankingScore != 0.5 {
+		t.Errorf("page after 1 of 2 = %+v, want the second and third hits", page)
+	}
+	if page := mergeByScore(hits, 10, 2); len(page) != 0 {
+		t.Errorf("page past the end = %+v, want no hits", page)
+	}
+}
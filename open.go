@@ -0,0 +1,24 @@
+package main
+
+import (
+	"os/exec"
+	"runtime"
+)
+
+// runCommand starts an external command without waiting for it to finish. It
+// is a variable so the browser isn't actually launched in tests
+var runCommand = func(name string, args ...string) error {
+	return exec.Command(name, args...).Start()
+}
+
+// openURL opens the url in the default browser
+func openURL(url string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		return runCommand("open", url)
+	case "windows":
+		return runCommand("cmd", "/c", "start", "", url)
+	default:
+		return runCommand("xdg-open", url)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// progressBarWidth is the number of characters inside the progress bar
+const progressBarWidth = 20
+
+// percent returns done as a percentage of total, capped at 100 since the
+// collection counts reported by Raindrop can lag behind the real contents
+func percent(done int, total int) int {
+	if total <= 0 {
+		return 0
+	}
+	p := done * 100 / total
+	if p > 100 {
+		return 100
+	}
+	return p
+}
+
+// progressBar renders a bar like "[#####---------------]  25% (50/200)"
+func progressBar(done int, total int) string {
+	p := percent(done, total)
+	filled := p * progressBarWidth / 100
+	bar := strings.Repeat("#", filled) + strings.Repeat("-", progressBarWidth-filled)
+	return fmt.Sprintf("[%s] %3d%% (%d/%d)", bar, p, done, total)
+}
+
+// showSpinner reports whether indexing progress is animated on stdout, which
+// only happens on a terminal and when neither -quiet nor -no-spinner is set
+func showSpinner(stdout io.Writer, quiet bool, noSpinner bool) bool {
+	return !quiet && !noSpinner && isTerminal(stdout)
+}
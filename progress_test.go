@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestProgressBar(t *testing.T) {
+	tests := []struct {
+		done  int
+		total int
+		want  string
+	}{
+		{0, 200, "[--------------------]   0% (0/200)"},
+		{50, 200, "[#####---------------]  25% (50/200)"},
+		{200, 200, "[####################] 100% (200/200)"},
+		{250, 200, "[####################] 100% (250/200)"},
+		{3, 0, "[--------------------]   0% (3/0)"},
+	}
+	for _, test := range tests {
+		if got := progressBar(test.done, test.total); got != test.want {
+			t.Errorf("progressBar(%d, %d) = %q, want %q", test.done, test.total, got, test.want)
+		}
+	}
+}
+
+func TestShowSpinner(t *testing.T) {
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+
+	for _, stdout := range []io.Writer{&bytes.Buffer{}, devNull} {
+		if showSpinner(stdout, false, false) {
+			t.Errorf("spinner shown on %T, want it off when stdout isn't a terminal", stdout)
+		}
+	}
+	if showSpinner(os.Stdout, true, false) || showSpinner(os.Stdout, false, true) {
+		t.Error("spinner shown with -quiet or -no-spinner")
+	}
+}
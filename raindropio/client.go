@@ -0,0 +1,324 @@
+// Package raindropio is a small client for the Raindrop.io REST API, used by
+// dropsearch to fetch bookmarks but usable on its own
+package raindropio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultURL is where the Raindrop API is reached unless BaseURL says
+// otherwise
+const DefaultURL = "https://api.raindrop.io"
+
+// raindropsPerPage is the page size requested from the Raindrop API, which
+// caps perpage at 50
+const raindropsPerPage = 50
+
+// DefaultRetries is how many times a failed request is retried unless
+// Retries says otherwise
+const DefaultRetries = 3
+
+// RetryBackoff is the delay before the first retry of a failed request, it
+// doubles with every following attempt
+const RetryBackoff = 500 * time.Millisecond
+
+// maxRedirects is how many redirects are followed before a request fails
+const maxRedirects = 10
+
+// maxErrorBodyLength is how much of an error response body is included in
+// error messages
+const maxErrorBodyLength = 200
+
+// ErrInvalidToken is returned when the API rejects the token
+var ErrInvalidToken = errors.New("invalid Raindrop token")
+
+// Client makes authenticated requests to the Raindrop API
+type Client struct {
+	// Token is sent as a bearer token with every request
+	Token string
+	// BaseURL is where the API is reached, without a trailing slash
+	BaseURL string
+	// HTTPClient sends the requests
+	HTTPClient *http.Client
+	// Retries is how many times a failed request is retried
+	Retries int
+}
+
+// NewClient returns a client for the default Raindrop API. Its HTTP
+// client drops the token when a redirect leaves the API host
+func NewClient(token string) *Client {
+	c := &Client{
+		Token:   token,
+		BaseURL: DefaultURL,
+		Retries: DefaultRetries,
+	}
+	c.HTTPClient = &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if !c.isAPIHost(req.URL) {
+				req.Header.Del("Authorization")
+			}
+			return nil
+		},
+	}
+	return c
+}
+
+// isAPIHost reports whether u has the same scheme and host as BaseURL, only
+// those requests are sent the token
+func (c *Client) isAPIHost(u *url.URL) bool {
+	base, err := url.Parse(c.BaseURL)
+	if err != nil {
+		return false
+	}
+	return u.Scheme == base.Scheme && u.Host == base.Host
+}
+
+// RaindropsInCollection returns every raindrop in the collection, fetching
+// as many pages as needed
+func (c *Client) RaindropsInCollection(ctx context.Context, collectionId int) ([]Raindrop, error) {
+	var raindrops []Raindrop
+	for page := 0; ; page++ {
+		raindropsResponse, err := c.raindropsPage(ctx, collectionId, page)
+		if err != nil {
+			return nil, err
+		}
+		if len(raindropsResponse.Items) == 0 {
+			break
+		}
+		raindrops = append(raindrops, raindropsResponse.Items...)
+		if raindropsResponse.Count > 0 && len(raindrops) >= raindropsResponse.Count {
+			break
+		}
+	}
+
+	return raindrops, nil
+}
+
+// Raindrop returns a single raindrop by id
+func (c *Client) Raindrop(ctx context.Context, id int) (*Raindrop, error) {
+	body, err := c.get(ctx, fmt.Sprintf("/rest/v1/raindrop/%d", id))
+	if err != nil {
+		return nil, err
+	}
+
+	var raindropResponse RaindropResponse
+	err = json.Unmarshal(body, &raindropResponse)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling response: %w", err)
+	}
+
+	return &raindropResponse.Item, nil
+}
+
+// CachedPage returns Raindrop's cached copy of the raindrop's page, the API
+// redirects to where the copy is stored
+func (c *Client) CachedPage(ctx context.Context, id int) ([]byte, error) {
+	return c.get(ctx, fmt.Sprintf("/rest/v1/raindrop/%d/cache", id))
+}
+
+func (c *Client) raindropsPage(ctx context.Context, collectionId int, page int) (*RaindropsResponse, error) {
+	path := fmt.Sprintf("/rest/v1/raindrops/%d?page=%d&perpage=%d", collectionId, page, raindropsPerPage)
+
+	body, err := c.get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raindropsResponse RaindropsResponse
+	err = json.Unmarshal(body, &raindropsResponse)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling response: %w", err)
+	}
+
+	return &raindropsResponse, nil
+}
+
+// Collections returns the root collections followed by all of the nested
+// collections
+func (c *Client) Collections(ctx context.Context) ([]Collection, error) {
+	roots, err := c.collectionList(ctx, "/rest/v1/collections")
+	if err != nil {
+		return nil, err
+	}
+
+	children, err := c.collectionList(ctx, "/rest/v1/collections/childrens")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(roots, children...), nil
+}
+
+func (c *Client) collectionList(ctx context.Context, path string) ([]Collection, error) {
+	body, err := c.get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var collectionResponse CollectionsResponse
+	err = json.Unmarshal(body, &collectionResponse)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling response: %w", err)
+	}
+
+	return collectionResponse.Collections, nil
+}
+
+// Tags returns every tag in use along with how many raindrops have it
+func (c *Client) Tags(ctx context.Context) ([]Tag, error) {
+	body, err := c.get(ctx, "/rest/v1/tags")
+	if err != nil {
+		return nil, err
+	}
+
+	var tagsResponse TagsResponse
+	err = json.Unmarshal(body, &tagsResponse)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling response: %w", err)
+	}
+
+	return tagsResponse.Items, nil
+}
+
+// User returns the account the token belongs to, it is a cheap way to check
+// the token works
+func (c *Client) User(ctx context.Context) (*Account, error) {
+	body, err := c.get(ctx, "/rest/v1/user")
+	if err != nil {
+		return nil, err
+	}
+
+	var userResponse UserResponse
+	err = json.Unmarshal(body, &userResponse)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling response: %w", err)
+	}
+
+	return &userResponse.User, nil
+}
+
+// ParseBaseURL checks that value is an absolute http(s) URL and strips any
+// trailing slash so paths can be appended to it
+func ParseBaseURL(value string) (string, error) {
+	u, err := url.Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("error parsing url: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("%q is not an http or https url", value)
+	}
+	return strings.TrimRight(value, "/"), nil
+}
+
+// get makes an authenticated GET request for path and returns the response
+// body
+func (c *Client) get(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.BaseURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	if c.isAPIHost(req.URL) {
+		req.Header.Add("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, statusError(resp.StatusCode, body)
+	}
+
+	return body, nil
+}
+
+// statusError describes a non-2xx Raindrop response, including the start of
+// the body since it usually holds the reason
+func statusError(statusCode int, body []byte) error {
+	snippet := strings.TrimSpace(string(body))
+	if len(snippet) > maxErrorBodyLength {
+		snippet = snippet[:maxErrorBodyLength] + "..."
+	}
+
+	if statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden {
+		return fmt.Errorf("%w (%d %s), check DROPSEARCH_RAINDROP_TOKEN: %s", ErrInvalidToken, statusCode, http.StatusText(statusCode), snippet)
+	}
+	return fmt.Errorf("unexpected response (%d %s): %s", statusCode, http.StatusText(statusCode), snippet)
+}
+
+// doWithRetry sends the request, retrying network errors, 5xx responses and
+// rate limiting up to c.Retries times. Rate limited requests wait for as long
+// as Retry-After asks, everything else uses exponential backoff. Any other
+// response is returned to the caller as is
+func (c *Client) doWithRetry(req *http.Request) (*http.Response, error) {
+	backoff := RetryBackoff
+	for attempt := 0; ; attempt++ {
+		resp, err := c.HTTPClient.Do(req)
+		if err == nil && resp.StatusCode < http.StatusInternalServerError && resp.StatusCode != http.StatusTooManyRequests {
+			return resp, nil
+		}
+		if attempt >= c.Retries {
+			return resp, err
+		}
+
+		wait := backoff
+		if err == nil {
+			if resp.StatusCode == http.StatusTooManyRequests {
+				wait = retryAfter(resp.Header.Get("Retry-After"), backoff, time.Now())
+				slog.Warn("rate limited by Raindrop", slog.String("retry_in", wait.String()))
+			}
+			resp.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
+
+// retryAfter parses a Retry-After header, which is either a number of seconds
+// or an HTTP date, returning fallback if it is missing or invalid
+func retryAfter(header string, fallback time.Duration, now time.Time) time.Duration {
+	if header == "" {
+		return fallback
+	}
+
+	seconds, err := strconv.Atoi(header)
+	if err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	at, err := http.ParseTime(header)
+	if err == nil {
+		if wait := at.Sub(now); wait > 0 {
+			return wait
+		}
+		return 0
+	}
+
+	return fallback
+}
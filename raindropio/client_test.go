@@ -0,0 +1,299 @@
+package raindropio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client for server that gives up after retries
+func newTestClient(server *httptest.Server, retries int) *Client {
+	client := NewClient("test-token")
+	client.BaseURL = server.URL
+	client.Retries = retries
+	return client
+}
+
+func TestRaindropsInCollectionPages(t *testing.T) {
+	pages := [][]int{{1, 2}, {3, 4}, {5}}
+	var requested []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer test-token" {
+			t.Errorf("Authorization = %q, want the bearer token", got)
+		}
+		if r.URL.Path != "/rest/v1/raindrops/7" {
+			t.Errorf("path = %q, want /rest/v1/raindrops/7", r.URL.Path)
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		requested = append(requested, page)
+
+		fmt.Fprint(w, `{"result": true, "count": 5, "items": [`)
+		if page < len(pages) {
+			for i, id := range pages[page] {
+				if i > 0 {
+					fmt.Fprint(w, ",")
+				}
+				fmt.Fprintf(w, `{"_id": %d}`, id)
+			}
+		}
+		fmt.Fprint(w, `]}`)
+	}))
+	defer server.Close()
+
+	raindrops, err := newTestClient(server, 0).RaindropsInCollection(context.Background(), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(raindrops) != 5 {
+		t.Fatalf("got %d raindrops, want 5", len(raindrops))
+	}
+	for i, raindrop := range raindrops {
+		if raindrop.ID != i+1 {
+			t.Errorf("raindrop %d has id %d, want %d", i, raindrop.ID, i+1)
+		}
+	}
+	if fmt.Sprint(requested) != "[0 1 2]" {
+		t.Errorf("requested pages %v, want [0 1 2] and no more once count is reached", requested)
+	}
+}
+
+func TestRaindropsInCollectionStopsOnEmptyPage(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Query().Get("page") == "0" {
+			fmt.Fprint(w, `{"items": [{"_id": 1}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"items": []}`)
+	}))
+	defer server.Close()
+
+	raindrops, err := newTestClient(server, 0).RaindropsInCollection(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(raindrops) != 1 || requests != 2 {
+		t.Errorf("got %d raindrops in %d requests, want 1 in 2", len(raindrops), requests)
+	}
+}
+
+func TestRetryOnServerError(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"user": {"email": "someone@example.com"}}`)
+	}))
+	defer server.Close()
+
+	account, err := newTestClient(server, 1).User(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Email != "someone@example.com" || requests != 2 {
+		t.Errorf("got %q after %d requests, want the account after 2", account.Email, requests)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Error(w, "down for maintenance", http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	_, err := newTestClient(server, 0).User(context.Background())
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if requests != 1 {
+		t.Errorf("made %d requests, want 1 without retries", requests)
+	}
+}
+
+func TestRequestDeadline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	_, err := newTestClient(server, DefaultRetries).Collections(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want a context deadline error", err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("took %s to give up after the deadline", elapsed)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	client := newTestClient(server, 0)
+	client.HTTPClient.Timeout = 50 * time.Millisecond
+	started := time.Now()
+	_, err := client.Collections(ctx)
+	if err == nil {
+		t.Fatal("got no error from a request slower than the timeout")
+	}
+	if ctx.Err() != nil {
+		t.Errorf("overall context ended with %v, want it still alive", ctx.Err())
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("took %s to give up after the request timeout", elapsed)
+	}
+}
+
+func TestRetryAfterRateLimit(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, `{"items": [{"_id": "go", "count": 3}]}`)
+	}))
+	defer server.Close()
+
+	started := time.Now()
+	tags, err := newTestClient(server, 1).Tags(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 1 || requests != 2 {
+		t.Errorf("got %d tags after %d requests, want 1 after 2", len(tags), requests)
+	}
+	if elapsed := time.Since(started); elapsed >= RetryBackoff {
+		t.Errorf("took %s, Retry-After: 0 should retry without the backoff", elapsed)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", time.Second},
+		{"3", 3 * time.Second},
+		{"0", 0},
+		{now.Add(10 * time.Second).Format(http.TimeFormat), 10 * time.Second},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"soon", time.Second},
+		{"-1", time.Second},
+	}
+	for _, test := range tests {
+		if got := retryAfter(test.header, time.Second, now); got != test.want {
+			t.Errorf("retryAfter(%q) = %s, want %s", test.header, got, test.want)
+		}
+	}
+}
+
+func TestParseBaseURL(t *testing.T) {
+	got, err := ParseBaseURL("http://localhost:8080/")
+	if err != nil || got != "http://localhost:8080" {
+		t.Errorf("ParseBaseURL = %q, %v, want the url without the trailing slash", got, err)
+	}
+	for _, value := range []string{"localhost:8080", "ftp://example.com", "/rest"} {
+		if _, err := ParseBaseURL(value); err == nil {
+			t.Errorf("ParseBaseURL(%q) should fail", value)
+		}
+	}
+}
+
+func TestDefaultURLUsesHTTPS(t *testing.T) {
+	u, err := url.Parse(NewClient("test-token").BaseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "https" || u.Host != "api.raindrop.io" {
+		t.Errorf("default url is %s, want https://api.raindrop.io", u)
+	}
+}
+
+func TestTokenOnlySentToAPIHost(t *testing.T) {
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "" {
+			t.Errorf("redirected request was sent Authorization %q", auth)
+		}
+		fmt.Fprint(w, "<p>cached</p>")
+	}))
+	defer storage.Close()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, storage.URL+"/page", http.StatusFound)
+	}))
+	defer api.Close()
+
+	page, err := newTestClient(api, 0).CachedPage(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(page) != "<p>cached</p>" {
+		t.Errorf("got %q, want the redirected page", page)
+	}
+}
+
+func TestUser(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/v1/user" {
+			t.Errorf("path = %q, want /rest/v1/user", r.URL.Path)
+		}
+		fmt.Fprint(w, `{"result": true, "user": {"_id": 42, "email": "me@example.com", "fullName": "Me"}}`)
+	}))
+	defer server.Close()
+
+	account, err := newTestClient(server, 0).User(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.ID != 42 || account.Email != "me@example.com" {
+		t.Errorf("got %+v, want the account with its email", account)
+	}
+}
+
+func TestInvalidToken(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Error(w, `{"error": "unauthorized"}`, http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	_, err := newTestClient(server, DefaultRetries).User(context.Background())
+	if !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("got %v, want ErrInvalidToken", err)
+	}
+	if requests != 1 {
+		t.Errorf("made %d requests, want a bad token not to be retried", requests)
+	}
+}
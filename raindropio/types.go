@@ -0,0 +1,150 @@
+package raindropio
+
+import (
+	"time"
+)
+
+// Collection is a Raindrop collection, the folders bookmarks are kept in
+type Collection struct {
+	ID            int       `json:"_id"`
+	Access        Access    `json:"access"`
+	Collaborators struct{}  `json:"collaborators"` // Assuming you don't need details here
+	Color         string    `json:"color"`
+	Count         int       `json:"count"`
+	Cover         []string  `json:"cover"`
+	Created       time.Time `json:"created"`
+	Expanded      bool      `json:"expanded"`
+	LastUpdate    time.Time `json:"lastUpdate"`
+	Parent        *Parent   `json:"parent"` // Optional, hence a pointer
+	Public        bool      `json:"public"`
+	Sort          int       `json:"sort"`
+	Title         string    `json:"title"`
+	User          User      `json:"user"`
+	View          string    `json:"view"`
+}
+
+// Access describes what the user may do with a collection
+type Access struct {
+	Level     int  `json:"level"`
+	Draggable bool `json:"draggable"`
+}
+
+// Parent points at the collection a nested collection belongs to
+type Parent struct {
+	ID int `json:"$id"`
+}
+
+// User points at the owner of a collection
+type User struct {
+	ID int `json:"$id"`
+}
+
+// CollectionsResponse is the body of the collections endpoints
+type CollectionsResponse struct {
+	Result      bool         `json:"result"`
+	Collections []Collection `json:"items"`
+}
+
+// Raindrop is a single bookmark
+type Raindrop struct {
+	ID         int `json:"_id"`
+	Collection struct {
+		ID int `json:"$id"`
+	} `json:"collection"`
+	Cover      string    `json:"cover"`
+	Created    time.Time `json:"created"`
+	Domain     string    `json:"domain"`
+	Excerpt    string    `json:"excerpt"`
+	Note       string    `json:"note"`
+	LastUpdate time.Time `json:"lastUpdate"`
+	Link       string    `json:"link"`
+	Media      []struct {
+		Link string `json:"link"`
+	} `json:"media"`
+	Tags  []string `json:"tags"`
+	Title string   `json:"title"`
+	Type  string   `json:"type"`
+	User  struct {
+		ID int `json:"$id"`
+	} `json:"user"`
+	Broken bool `json:"broken"`
+	Cache  struct {
+		Status  string    `json:"status"`
+		Size    int       `json:"size"`
+		Created time.Time `json:"created"`
+	} `json:"cache"`
+	CreatorRef struct {
+		ID       int    `json:"_id"`
+		FullName string `json:"fullName"`
+	} `json:"creatorRef"`
+	File struct {
+		Name string `json:"name"`
+		Size int    `json:"size"`
+		Type string `json:"type"`
+	} `json:"file"`
+	Important  bool `json:"important"`
+	Highlights []struct {
+		ID      string    `json:"_id"`
+		Text    string    `json:"text"`
+		Color   string    `json:"color"`
+		Note    string    `json:"note"`
+		Created time.Time `json:"created"`
+	} `json:"highlights"`
+
+	// Fields below aren't sent by the API, dropsearch derives them while
+	// indexing to make searching easier
+
+	// CollectionID copies Collection.ID to a top level filterable field
+	CollectionID int `json:"collection_id,omitempty"`
+	// HighlightText joins the text of every highlight so they are searchable
+	HighlightText string `json:"highlight_text,omitempty"`
+	// CollectionTitle is the title of the bookmark's collection
+	CollectionTitle string `json:"collection_title,omitempty"`
+	// CollectionPath is the slash joined titles of the collection and its
+	// parents, like "Dev/Go/Tools"
+	CollectionPath string `json:"collection_path,omitempty"`
+	// CreatedUnix is Created as a unix timestamp so it can be range filtered
+	CreatedUnix int64 `json:"created_unix,omitempty"`
+	// Favicon is the URL of the domain's icon from a favicon service
+	Favicon string `json:"favicon,omitempty"`
+	// Content is the text of Raindrop's cached copy of the page, only
+	// fetched with -fetch-cache
+	Content string `json:"content,omitempty"`
+}
+
+// RaindropResponse is the body of the single raindrop endpoint
+type RaindropResponse struct {
+	Result bool     `json:"result"`
+	Item   Raindrop `json:"item"`
+}
+
+// RaindropsResponse is one page of raindrops in a collection
+type RaindropsResponse struct {
+	Count int        `json:"count"`
+	Items []Raindrop `json:"items"`
+}
+
+// Tag is a tag along with how many raindrops have it
+type Tag struct {
+	Name  string `json:"_id"`
+	Count int    `json:"count"`
+}
+
+// TagsResponse is the body of the tags endpoint
+type TagsResponse struct {
+	Result bool  `json:"result"`
+	Items  []Tag `json:"items"`
+}
+
+// Account is the user a token belongs to
+type Account struct {
+	ID       int    `json:"_id"`
+	Email    string `json:"email"`
+	FullName string `json:"fullName"`
+}
+
+// UserResponse is the body of the user endpoint
+type UserResponse struct {
+	Result bool    `json:"result"`
+	User   Account `json:"user"`
+}
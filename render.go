@@ -0,0 +1,458 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/fatih/color"
+	"github.com/zpeters/dropsearch/raindropio"
+	"github.com/zpeters/dropsearch/searchindex"
+	"html/template"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+)
+
+// searchResults is everything a renderer needs to write out a search
+type searchResults struct {
+	Query          string
+	Hits           []searchindex.Hit
+	Offset         int64
+	Limit          int64
+	EstimatedTotal int64
+}
+
+// renderer writes search results in one output format
+type renderer interface {
+	render(w io.Writer, results searchResults) error
+}
+
+// renderers maps the values accepted by -format to their renderer
+var renderers = map[string]renderer{
+	"text":     textRenderer{},
+	"json":     jsonRenderer{},
+	"jsonl":    jsonlRenderer{},
+	"markdown": markdownRenderer{},
+	"csv":      csvRenderer{},
+	"html":     htmlRenderer{},
+}
+
+// newRenderer returns the renderer for an output format
+func newRenderer(format string) (renderer, error) {
+	r, ok := renderers[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q, use one of %s", format, strings.Join(rendererNames(), ", "))
+	}
+	return r, nil
+}
+
+// rendererNames returns the sorted names of the output formats
+func rendererNames() []string {
+	names := make([]string, 0, len(renderers))
+	for name := range renderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// textFields are the fields -fields can show in text output, in the order
+// they are printed
+var textFields = []string{"title", "link", "excerpt", "note", "highlight", "collection", "domain", "created", "type", "tags", "cache", "cover"}
+
+// defaultTextFields are shown when -fields isn't given
+var defaultTextFields = []string{"title", "link", "excerpt", "highlight", "collection", "domain", "created", "tags"}
+
+// parseFields reads a comma separated -fields value, failing on names that
+// aren't in textFields
+func parseFields(value string) (map[string]bool, error) {
+	fields := make(map[string]bool)
+	for _, field := range splitList(value) {
+		known := false
+		for _, textField := range textFields {
+			if field == textField {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown field %q, use any of %s", field, strings.Join(textFields, ","))
+		}
+		fields[field] = true
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no fields given, use any of %s", strings.Join(textFields, ","))
+	}
+	return fields, nil
+}
+
+// textRenderer writes numbered, colored results for reading in a terminal
+type textRenderer struct {
+	// Fields are the fields printed for each hit, nil means defaultTextFields
+	Fields map[string]bool
+	// Explain prints the ranking score and matched fields of each hit
+	Explain bool
+}
+
+func (r textRenderer) render(w io.Writer, results searchResults) error {
+	slog.Info(hitSummary(len(results.Hits), results.EstimatedTotal, results.Query))
+
+	fields := r.Fields
+	if fields == nil {
+		fields, _ = parseFields(strings.Join(defaultTextFields, ","))
+	}
+
+	textColor := color.New(color.Reset)
+	matchColor := color.New(color.FgHiYellow, color.Bold)
+	linkColor := color.New(color.FgBlue).SprintFunc()
+	infoColor := color.New(color.Faint).SprintFunc()
+	tagColor := color.New(color.FgYellow).SprintFunc()
+
+	for i, hit := range results.Hits {
+		raindrop := hit.Raindrop
+		fmt.Fprintf(w, "%d.", int64(i)+results.Offset+1)
+		if fields["title"] {
+			if hit.Source != "" {
+				fmt.Fprintf(w, " %s", infoColor("["+hit.Source+"]"))
+			}
+			title := hit.FormattedField("title", raindrop.Title)
+			fmt.Fprintf(w, " %s", colorMatches(title, typeColor(raindrop.Type), matchColor))
+		}
+		fmt.Fprintln(w)
+		if fields["link"] {
+			fmt.Fprintf(w, "   Link: %s\n", linkColor(raindrop.Link))
+		}
+		if fields["excerpt"] && raindrop.Excerpt != "" {
+			excerpt := hit.FormattedField("excerpt", raindrop.Excerpt)
+			fmt.Fprintf(w, "   Excerpt: %s\n", colorMatches(excerpt, textColor, matchColor))
+		}
+		if fields["note"] && raindrop.Note != "" {
+			fmt.Fprintf(w, "   Note: %s\n", raindrop.Note)
+		}
+		if snippet := hit.HighlightSnippet(); fields["highlight"] && snippet != "" {
+			fmt.Fprintf(w, "   Highlight: %s\n", colorMatches(snippet, textColor, matchColor))
+		}
+		if label := collectionLabel(raindrop); fields["collection"] && label != "" {
+			fmt.Fprintf(w, "   Collection: %s\n", infoColor(label))
+		}
+		var details []string
+		if fields["domain"] {
+			details = append(details, "Domain: "+infoColor(raindrop.Domain))
+		}
+		if fields["created"] {
+			details = append(details, "Created: "+infoColor(raindrop.Created.Format("2006-01-02")))
+		}
+		if fields["type"] && raindrop.Type != "" {
+			details = append(details, "Type: "+infoColor(raindrop.Type))
+		}
+		if len(details) > 0 {
+			fmt.Fprintf(w, "   %s\n", strings.Join(details, ", "))
+		}
+		if fields["tags"] && len(raindrop.Tags) > 0 {
+			fmt.Fprintf(w, "   Tags: %s\n", tagColor(strings.Join(raindrop.Tags, ", ")))
+		}
+		if fields["cache"] && raindrop.Cache.Status != "" {
+			fmt.Fprintf(w, "   Cached: %s\n", infoColor(raindrop.Cache.Status))
+		}
+		if fields["cover"] && raindrop.Cover != "" {
+			fmt.Fprintf(w, "   Cover: %s\n", linkColor(raindrop.Cover))
+		}
+		if r.Explain {
+			fmt.Fprintf(w, "   %s\n", infoColor(explanation(hit)))
+		}
+		fmt.Fprintln(w)
+	}
+
+	if footer := paginationFooter(results.Offset, len(results.Hits), results.Limit, results.EstimatedTotal); footer != "" {
+		fmt.Fprintln(w, infoColor(footer))
+	}
+
+	return nil
+}
+
+// typeColors are the title colors for each bookmark type, unknown types use
+// the link color
+var typeColors = map[string]color.Attribute{
+	"link":     color.FgGreen,
+	"article":  color.FgCyan,
+	"image":    color.FgMagenta,
+	"video":    color.FgRed,
+	"document": color.FgBlue,
+	"audio":    color.FgHiMagenta,
+}
+
+// typeColor returns the title color for a bookmark type
+func typeColor(raindropType string) *color.Color {
+	attribute, ok := typeColors[raindropType]
+	if !ok {
+		attribute = typeColors["link"]
+	}
+	return color.New(attribute)
+}
+
+// printLegend writes each bookmark type in the color its titles are shown in
+func printLegend(w io.Writer) {
+	for _, raindropType := range searchindex.RaindropTypes {
+		fmt.Fprintln(w, typeColor(raindropType).Sprint(raindropType))
+	}
+}
+
+// paginationFooter describes which results are shown out of the estimated
+// total, pointing at the -offset of the next page when there is one
+func paginationFooter(offset int64, shown int, limit int64, estimatedTotal int64) string {
+	if shown == 0 {
+		if offset > 0 && estimatedTotal > 0 {
+			return fmt.Sprintf("no results past ~%d, use a smaller -offset", estimatedTotal)
+		}
+		return ""
+	}
+
+	first := offset + 1
+	last := offset + int64(shown)
+	if int64(shown) < limit || estimatedTotal <= last {
+		return fmt.Sprintf("results %d–%d of %d", first, last, last)
+	}
+	return fmt.Sprintf("results %d–%d of ~%d; use -offset %d for next page", first, last, estimatedTotal, last)
+}
+
+// explanation describes why a hit matched, its ranking score from 0 to 1 and
+// the fields the query was found in
+func explanation(hit searchindex.Hit) string {
+	matched := "no fields"
+	if fields := hit.MatchedFields(); len(fields) > 0 {
+		matched = strings.Join(fields, ", ")
+	}
+	return fmt.Sprintf("Score: %.4f, matched in %s", hit.RankingScore, matched)
+}
+
+// collectionLabel names the bookmark's collection by its path, falling back
+// to the title and then the id when the collection couldn't be resolved
+func collectionLabel(raindrop raindropio.Raindrop) string {
+	switch {
+	case raindrop.CollectionPath != "":
+		return raindrop.CollectionPath
+	case raindrop.CollectionTitle != "":
+		return raindrop.CollectionTitle
+	case raindrop.CollectionID != 0:
+		return fmt.Sprintf("#%d", raindrop.CollectionID)
+	default:
+		return ""
+	}
+}
+
+// hitSummary describes how many hits are shown, mentioning meilisearch's
+// estimate of the total when the results were cut off by the limit
+func hitSummary(shown int, estimatedTotal int64, query string) string {
+	hitCountColor := color.New(color.FgHiYellow).SprintfFunc()
+	queryColor := color.New(color.FgHiCyan).SprintFunc()
+
+	if estimatedTotal <= int64(shown) {
+		return fmt.Sprintf("found %s hits for '%s'", hitCountColor("%d", shown), queryColor(query))
+	}
+	return fmt.Sprintf("showing %s of ~%s hits for '%s'", hitCountColor("%d", shown), hitCountColor("%d", estimatedTotal), queryColor(query))
+}
+
+// colorMatches colors text with base, except for the terms marked by the
+// highlight tags which are colored with match instead
+func colorMatches(text string, base *color.Color, match *color.Color) string {
+	var b strings.Builder
+	for text != "" {
+		start := strings.Index(text, searchindex.HighlightPreTag)
+		if start < 0 {
+			b.WriteString(base.Sprint(text))
+			break
+		}
+		if start > 0 {
+			b.WriteString(base.Sprint(text[:start]))
+		}
+		text = text[start+len(searchindex.HighlightPreTag):]
+
+		end := strings.Index(text, searchindex.HighlightPostTag)
+		if end < 0 {
+			end = len(text)
+		}
+		b.WriteString(match.Sprint(text[:end]))
+		text = strings.TrimPrefix(text[end:], searchindex.HighlightPostTag)
+	}
+	return b.String()
+}
+
+// jsonRenderer writes the raindrops as a single JSON array so the output can
+// be piped into tools like jq
+type jsonRenderer struct{}
+
+func (jsonRenderer) render(w io.Writer, results searchResults) error {
+	raindrops := make([]sourcedRaindrop, 0, len(results.Hits))
+	for _, hit := range results.Hits {
+		raindrops = append(raindrops, sourcedRaindrop{Raindrop: hit.Raindrop, Source: hit.Source})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(raindrops)
+}
+
+// sourcedRaindrop is a raindrop in JSON output, along with where it came from
+// when searching several indexes
+type sourcedRaindrop struct {
+	raindropio.Raindrop
+	Source string `json:"source,omitempty"`
+}
+
+// jsonlRenderer writes each raindrop as its own line of JSON, for tools that
+// read a stream of objects
+type jsonlRenderer struct{}
+
+func (jsonlRenderer) render(w io.Writer, results searchResults) error {
+	encoder := json.NewEncoder(w)
+	for _, hit := range results.Hits {
+		err := encoder.Encode(sourcedRaindrop{Raindrop: hit.Raindrop, Source: hit.Source})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markdownRenderer writes each hit as a markdown list item, ready to paste
+// into notes
+type markdownRenderer struct {
+	// Favicon shows the favicon in front of each link
+	Favicon bool
+}
+
+// markdownEscaper escapes the characters that would break a link title
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, `[`, `\[`, `]`, `\]`)
+
+// markdownURLEscaper percent-encodes the characters that would end a link
+// destination early, like the closing parenthesis in a Wikipedia URL
+var markdownURLEscaper = strings.NewReplacer("(", "%28", ")", "%29", " ", "%20")
+
+func (r markdownRenderer) render(w io.Writer, results searchResults) error {
+	for _, hit := range results.Hits {
+		raindrop := hit.Raindrop
+		var favicon string
+		if r.Favicon && raindrop.Favicon != "" {
+			favicon = fmt.Sprintf("![](%s) ", markdownURLEscaper.Replace(raindrop.Favicon))
+		}
+		fmt.Fprintf(w, "- %s[%s](%s)\n", favicon, markdownEscaper.Replace(raindrop.Title), markdownURLEscaper.Replace(raindrop.Link))
+		if raindrop.Cover != "" {
+			fmt.Fprintf(w, "  ![%s](%s)\n", markdownEscaper.Replace(raindrop.Title), markdownURLEscaper.Replace(raindrop.Cover))
+		}
+		if raindrop.Excerpt != "" {
+			fmt.Fprintf(w, "  %s\n", strings.Join(strings.Fields(raindrop.Excerpt), " "))
+		}
+		if len(raindrop.Tags) > 0 {
+			tags := make([]string, 0, len(raindrop.Tags))
+			for _, tag := range raindrop.Tags {
+				tags = append(tags, "`"+tag+"`")
+			}
+			fmt.Fprintf(w, "  %s\n", strings.Join(tags, " "))
+		}
+	}
+	return nil
+}
+
+// csvRenderer writes a header row and one row per hit for spreadsheets
+type csvRenderer struct{}
+
+func (csvRenderer) render(w io.Writer, results searchResults) error {
+	// the source column is only added when searching several indexes, so the
+	// columns of a single index search stay the same
+	withSource := false
+	for _, hit := range results.Hits {
+		withSource = withSource || hit.Source != ""
+	}
+
+	writer := csv.NewWriter(w)
+	header := []string{"title", "link", "domain", "created", "tags"}
+	if withSource {
+		header = append(header, "source")
+	}
+	err := writer.Write(header)
+	if err != nil {
+		return err
+	}
+
+	for _, hit := range results.Hits {
+		raindrop := hit.Raindrop
+		record := []string{
+			raindrop.Title,
+			raindrop.Link,
+			raindrop.Domain,
+			raindrop.Created.Format(time.RFC3339),
+			strings.Join(raindrop.Tags, ";"),
+		}
+		if withSource {
+			record = append(record, hit.Source)
+		}
+		err = writer.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// htmlRenderer writes a self-contained HTML page of the results for sharing,
+// html/template escapes every field and drops unsafe link schemes
+type htmlRenderer struct {
+	// Favicon shows the favicon in front of each title
+	Favicon bool
+}
+
+// htmlTemplate is the page written by htmlRenderer
+var htmlTemplate = template.Must(template.New("results").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>dropsearch: {{.Query}}</title>
+<style>
+body { font-family: sans-serif; max-width: 50em; margin: 2em auto; padding: 0 1em; color: #222; }
+li { margin-bottom: 1em; }
+a { font-weight: bold; }
+img { vertical-align: middle; }
+.excerpt { margin: 0.3em 0; }
+.meta { margin: 0.3em 0; color: #777; font-size: 0.9em; }
+.tag { background: #eee; border-radius: 3px; padding: 0 0.3em; }
+</style>
+</head>
+<body>
+<h1>Results for &ldquo;{{.Query}}&rdquo;</h1>
+<ol start="{{.Start}}">
+{{- range .Raindrops}}
+<li>
+<a href="{{.Link}}">{{if and $.Favicon .Favicon}}<img src="{{.Favicon}}" alt="" width="16" height="16"> {{end}}{{.Title}}</a>
+{{- with .Excerpt}}
+<p class="excerpt">{{.}}</p>
+{{- end}}
+<p class="meta">{{.Domain}} &middot; {{.Created.Format "2006-01-02"}}{{range .Tags}} <span class="tag">{{.}}</span>{{end}}</p>
+</li>
+{{- end}}
+</ol>
+</body>
+</html>
+`))
+
+func (r htmlRenderer) render(w io.Writer, results searchResults) error {
+	raindrops := make([]raindropio.Raindrop, 0, len(results.Hits))
+	for _, hit := range results.Hits {
+		raindrops = append(raindrops, hit.Raindrop)
+	}
+
+	return htmlTemplate.Execute(w, struct {
+		Query     string
+		Start     int64
+		Favicon   bool
+		Raindrops []raindropio.Raindrop
+	}{
+		Query:     results.Query,
+		Start:     results.Offset + 1,
+		Favicon:   r.Favicon,
+		Raindrops: raindrops,
+	})
+}
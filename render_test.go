@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/fatih/color"
+	"github.com/zpeters/dropsearch/raindropio"
+	"github.com/zpeters/dropsearch/searchindex"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withoutColor turns colors off for the rest of the test so output can be
+// compared as plain text
+func withoutColor(t *testing.T) {
+	t.Helper()
+	noColor := color.NoColor
+	color.NoColor = true
+	t.Cleanup(func() { color.NoColor = noColor })
+}
+
+func TestHitSummary(t *testing.T) {
+	withoutColor(t)
+	tests := []struct {
+		shown int
+		total int64
+		want  string
+	}{
+		{3, 3, "found 3 hits for 'go'"},
+		{0, 0, "found 0 hits for 'go'"},
+		{10, 243, "showing 10 of ~243 hits for 'go'"},
+	}
+	for _, test := range tests {
+		if got := hitSummary(test.shown, test.total, "go"); got != test.want {
+			t.Errorf("hitSummary(%d, %d) = %q, want %q", test.shown, test.total, got, test.want)
+		}
+	}
+}
+
+func TestColorMatches(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = false
+	t.Cleanup(func() { color.NoColor = noColor })
+
+	base := color.New(color.FgBlue)
+	match := color.New(color.Bold)
+	pre, post := searchindex.HighlightPreTag, searchindex.HighlightPostTag
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"plain", base.Sprint("plain")},
+		{"learn " + pre + "go" + post + " today", base.Sprint("learn ") + match.Sprint("go") + base.Sprint(" today")},
+		{pre + "go" + post, match.Sprint("go")},
+		{"cut " + pre + "off", base.Sprint("cut ") + match.Sprint("off")},
+	}
+	for _, test := range tests {
+		if got := colorMatches(test.text, base, match); got != test.want {
+			t.Errorf("colorMatches(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+// testHits are two search results with the fields every format shows
+func testHits() []searchindex.Hit {
+	return []searchindex.Hit{
+		{Raindrop: raindropio.Raindrop{
+			Title:   "Effective Go",
+			Link:    "https://go.dev/doc/effective_go",
+			Domain:  "go.dev",
+			Excerpt: "Tips for writing\nclear, idiomatic Go code.",
+			Created: time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC),
+			Tags:    []string{"go", "style"},
+		}},
+		{Raindrop: raindropio.Raindrop{
+			Title:   "Go [programming language]",
+			Link:    "https://en.wikipedia.org/wiki/Go_(programming_language)",
+			Domain:  "en.wikipedia.org",
+			Created: time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC),
+		}},
+	}
+}
+
+func TestMarkdownRenderer(t *testing.T) {
+	var b bytes.Buffer
+	err := markdownRenderer{}.render(&b, searchResults{Query: "go", Hits: testHits()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "- [Effective Go](https://go.dev/doc/effective_go)\n" +
+		"  Tips for writing clear, idiomatic Go code.\n" +
+		"  `go` `style`\n" +
+		"- [Go \\[programming language\\]](https://en.wikipedia.org/wiki/Go_%28programming_language%29)\n"
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestCSVRenderer(t *testing.T) {
+	hits := testHits()
+	hits[0].Title = "Effective Go, the guide"
+
+	var b bytes.Buffer
+	err := csvRenderer{}.render(&b, searchResults{Hits: hits})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "title,link,domain,created,tags\n" +
+		"\"Effective Go, the guide\",https://go.dev/doc/effective_go,go.dev,2024-03-05T10:00:00Z,go;style\n" +
+		"Go [programming language],https://en.wikipedia.org/wiki/Go_(programming_language),en.wikipedia.org,2023-01-02T00:00:00Z,\n"
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestCollectionLabel(t *testing.T) {
+	tests := []struct {
+		raindrop raindropio.Raindrop
+		want     string
+	}{
+		{raindropio.Raindrop{CollectionPath: "Dev/Go", CollectionTitle: "Go", CollectionID: 7}, "Dev/Go"},
+		{raindropio.Raindrop{CollectionTitle: "Go", CollectionID: 7}, "Go"},
+		{raindropio.Raindrop{CollectionID: 7}, "#7"},
+		{raindropio.Raindrop{}, ""},
+	}
+	for _, test := range tests {
+		if got := collectionLabel(test.raindrop); got != test.want {
+			t.Errorf("collectionLabel(%+v) = %q, want %q", test.raindrop, got, test.want)
+		}
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	fields, err := parseFields("title, link,,tags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 3 || !fields["title"] || !fields["link"] || !fields["tags"] {
+		t.Errorf("got %v, want title, link and tags", fields)
+	}
+
+	for _, value := range []string{"title,rating", "", " , "} {
+		if _, err := parseFields(value); err == nil {
+			t.Errorf("parseFields(%q) should fail", value)
+		}
+	}
+}
+
+func TestTextRendererFields(t *testing.T) {
+	withoutColor(t)
+	var b bytes.Buffer
+	renderer := textRenderer{Fields: map[string]bool{"title": true, "tags": true}}
+	err := renderer.render(&b, searchResults{Query: "go", Hits: testHits()[:1], Limit: 10, EstimatedTotal: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "1. Effective Go\n" +
+		"   Tags: go, style\n" +
+		"\n" +
+		"results 1–1 of 1\n"
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant only the chosen fields\n%s", b.String(), want)
+	}
+}
+
+func TestPaginationFooter(t *testing.T) {
+	tests := []struct {
+		name           string
+		offset         int64
+		shown          int
+		limit          int64
+		estimatedTotal int64
+		want           string
+	}{
+		{"first page", 0, 10, 10, 42, "results 1–10 of ~42; use -offset 10 for next page"},
+		{"middle page", 10, 10, 10, 42, "results 11–20 of ~42; use -offset 20 for next page"},
+		{"short last page", 40, 2, 10, 42, "results 41–42 of 42"},
+		{"full last page", 30, 10, 10, 40, "results 31–40 of 40"},
+		{"estimate below what was shown", 0, 10, 10, 7, "results 1–10 of 10"},
+		{"offset past the end", 50, 0, 10, 42, "no results past ~42, use a smaller -offset"},
+		{"no results", 0, 0, 10, 0, ""},
+		{"limit all", 0, 42, 0, 42, "results 1–42 of 42"},
+		{"limit all stopped at max results", 0, 100, 0, 250, "results 1–100 of ~250; use -offset 100 for next page"},
+	}
+	for _, test := range tests {
+		got := paginationFooter(test.offset, test.shown, test.limit, test.estimatedTotal)
+		if got != test.want {
+			t.Errorf("%s: paginationFooter() = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestTypeColor(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = false
+	t.Cleanup(func() { color.NoColor = noColor })
+
+	seen := make(map[string]string)
+	for _, raindropType := range searchindex.RaindropTypes {
+		colored := typeColor(raindropType).Sprint("title")
+		if other, ok := seen[colored]; ok {
+			t.Errorf("%s and %s share a color, want every type told apart", raindropType, other)
+		}
+		seen[colored] = raindropType
+	}
+	if typeColor("podcast").Sprint("title") != typeColor("link").Sprint("title") {
+		t.Error("unknown types should use the link color")
+	}
+
+	var legend bytes.Buffer
+	printLegend(&legend)
+	if lines := strings.Count(legend.String(), "\n"); lines != len(searchindex.RaindropTypes) {
+		t.Errorf("legend has %d lines, want one per type", lines)
+	}
+	if !strings.Contains(legend.String(), typeColor("video").Sprint("video")) {
+		t.Errorf("legend = %q, want each type in its own color", legend.String())
+	}
+}
+
+func TestCoverIsShown(t *testing.T) {
+	withoutColor(t)
+	hits := testHits()[:1]
+	hits[0].Cover = "https://go.dev/images/cover (large).png"
+
+	var b bytes.Buffer
+	err := markdownRenderer{}.render(&b, searchResults{Hits: hits})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "\n  ![Effective Go](https://go.dev/images/cover%20%28large%29.png)\n"; !strings.Contains(b.String(), want) {
+		t.Errorf("markdown\n%s\nwant the cover as an image %q", b.String(), want)
+	}
+
+	b.Reset()
+	renderer := textRenderer{Fields: map[string]bool{"title": true, "cover": true}}
+	err = renderer.render(&b, searchResults{Hits: hits, Limit: 10, EstimatedTotal: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "   Cover: https://go.dev/images/cover (large).png\n"; !strings.Contains(b.String(), want) {
+		t.Errorf("text\n%s\nwant the cover line %q", b.String(), want)
+	}
+}
+
+func TestJSONLRenderer(t *testing.T) {
+	var b bytes.Buffer
+	err := jsonlRenderer{}.render(&b, searchResults{Hits: testHits()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want one per hit:\n%s", len(lines), b.String())
+	}
+	for i, line := range lines {
+		var raindrop raindropio.Raindrop
+		err := json.Unmarshal([]byte(line), &raindrop)
+		if err != nil {
+			t.Errorf("line %d isn't a JSON object: %v", i+1, err)
+		}
+		if raindrop.Title != testHits()[i].Title {
+			t.Errorf("line %d has title %q, want %q", i+1, raindrop.Title, testHits()[i].Title)
+		}
+	}
+
+	b.Reset()
+	err = jsonlRenderer{}.render(&b, searchResults{})
+	if err != nil || b.Len() != 0 {
+		t.Errorf("no hits wrote %q, %v, want nothing", b.String(), err)
+	}
+}
+
+func TestExplanation(t *testing.T) {
+	tests := []struct {
+		hit  searchindex.Hit
+		want string
+	}{
+		{
+			searchindex.Hit{RankingScore: 0.91234, MatchesPosition: map[string]interface{}{"title": nil, "excerpt": nil}},
+			"Score: 0.9123, matched in excerpt, title",
+		},
+		{searchindex.Hit{RankingScore: 0.5}, "Score: 0.5000, matched in no fields"},
+	}
+	for _, test := range tests {
+		if got := explanation(test.hit); got != test.want {
+			t.Errorf("explanation() = %q, want %q", got, test.want)
+		}
+	}
+}
+
+func TestRenderersIncludeSource(t *testing.T) {
+	hits := testHits()
+	hits[0].Source = "work"
+
+	var b bytes.Buffer
+	err := jsonRenderer{}.render(&b, searchResults{Hits: hits})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `"source": "work"`) || strings.Count(b.String(), `"source"`) != 1 {
+		t.Errorf("json\n%s\nwant the source only on the hit that has one", b.String())
+	}
+
+	b.Reset()
+	err = csvRenderer{}.render(&b, searchResults{Hits: hits})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(b.String(), "\n")
+	if lines[0] != "title,link,domain,created,tags,source" || !strings.HasSuffix(lines[1], ",go;style,work") {
+		t.Errorf("csv\n%s\nwant a source column", b.String())
+	}
+}
+
+func TestHTMLRenderer(t *testing.T) {
+	hits := testHits()
+	hits[0].Title = `<script>alert("hi")</script> & Go`
+	hits[1].Link = "javascript:alert(1)"
+
+	var b bytes.Buffer
+	err := htmlRenderer{}.render(&b, searchResults{Query: "go <b>", Hits: hits, Offset: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := b.String()
+
+	for _, want := range []string{
+		`<title>dropsearch: go &lt;b&gt;</title>`,
+		`<ol start="11">`,
+		`<a href="https://go.dev/doc/effective_go">&lt;script&gt;alert(&#34;hi&#34;)&lt;/script&gt; &amp; Go</a>`,
+		`<a href="#ZgotmplZ">Go [programming language]</a>`,
+		`<span class="tag">go</span>`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page is missing %s:\n%s", want, page)
+		}
+	}
+	if strings.Contains(page, "<script>") {
+		t.Errorf("page contains an unescaped script tag:\n%s", page)
+	}
+}
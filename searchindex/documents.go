@@ -0,0 +1,202 @@
+package searchindex
+
+import (
+	"github.com/zpeters/dropsearch/raindropio"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// PrepareDocuments fills in the derived fields of each raindrop before it is
+// sent to meilisearch. titles and paths map collection ids to the collection
+// title and path, see CollectionTitles and CollectionPaths
+func PrepareDocuments(raindrops []raindropio.Raindrop, titles map[int]string, paths map[int]string) {
+	for i := range raindrops {
+		raindrops[i].CollectionID = raindrops[i].Collection.ID
+		raindrops[i].HighlightText = flattenHighlights(raindrops[i])
+		raindrops[i].CollectionTitle = titles[raindrops[i].Collection.ID]
+		raindrops[i].CollectionPath = paths[raindrops[i].Collection.ID]
+		raindrops[i].CreatedUnix = raindrops[i].Created.Unix()
+	}
+}
+
+// DefaultFaviconService is a favicon URL that works for any domain, {domain}
+// is replaced with the bookmark's domain
+const DefaultFaviconService = "https://www.google.com/s2/favicons?domain={domain}"
+
+// FaviconURL fills the domain into a favicon service URL, returning an empty
+// string for bookmarks without a domain
+func FaviconURL(service string, domain string) string {
+	if service == "" || domain == "" {
+		return ""
+	}
+	return strings.ReplaceAll(service, "{domain}", url.QueryEscape(domain))
+}
+
+// AddFavicons sets the favicon URL of each raindrop from its domain
+func AddFavicons(raindrops []raindropio.Raindrop, service string) {
+	for i := range raindrops {
+		raindrops[i].Favicon = FaviconURL(service, raindrops[i].Domain)
+	}
+}
+
+// NormalizeTags trims and lowercases tags, keeping the first of any that end
+// up the same so "Go" and " go " become a single "go"
+func NormalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// CollectionTitles maps every collection id to its title
+func CollectionTitles(collections []raindropio.Collection) map[int]string {
+	titles := make(map[int]string, len(collections))
+	for _, collection := range collections {
+		titles[collection.ID] = collection.Title
+	}
+	return titles
+}
+
+// CollectionPaths maps every collection id to the slash joined titles of the
+// collection and its parents. A cycle in the parents ends the path where the
+// chain starts repeating
+func CollectionPaths(collections []raindropio.Collection) map[int]string {
+	byID := make(map[int]raindropio.Collection, len(collections))
+	for _, collection := range collections {
+		byID[collection.ID] = collection
+	}
+
+	paths := make(map[int]string, len(collections))
+	for _, collection := range collections {
+		var titles []string
+		seen := make(map[int]bool)
+		current, ok := collection, true
+		for ok && !seen[current.ID] {
+			seen[current.ID] = true
+			titles = append([]string{current.Title}, titles...)
+			if current.Parent == nil {
+				break
+			}
+			current, ok = byID[current.Parent.ID]
+		}
+		paths[collection.ID] = strings.Join(titles, "/")
+	}
+
+	return paths
+}
+
+// flattenHighlights joins the text of a raindrop's highlights into a single
+// string, one highlight per line
+func flattenHighlights(raindrop raindropio.Raindrop) string {
+	texts := make([]string, 0, len(raindrop.Highlights))
+	for _, highlight := range raindrop.Highlights {
+		text := strings.TrimSpace(highlight.Text)
+		if text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// Deduper drops raindrops whose id has already been seen. Since documents are
+// upserted by id, a repeat is only let through when it was updated more
+// recently than the copy already sent, so the newest copy ends up indexed
+type Deduper struct {
+	// Duplicates counts every repeated id seen
+	Duplicates int
+
+	seen map[int]time.Time
+}
+
+// NewDeduper returns a Deduper that hasn't seen any raindrops yet
+func NewDeduper() *Deduper {
+	return &Deduper{seen: make(map[int]time.Time)}
+}
+
+// Filter returns the raindrops that are new or newer than the copy seen
+// before, counting every repeated id as a duplicate
+func (d *Deduper) Filter(raindrops []raindropio.Raindrop) []raindropio.Raindrop {
+	kept := make([]raindropio.Raindrop, 0, len(raindrops))
+	for _, raindrop := range raindrops {
+		lastUpdate, seen := d.seen[raindrop.ID]
+		if seen {
+			d.Duplicates++
+			if !raindrop.LastUpdate.After(lastUpdate) {
+				continue
+			}
+		}
+		d.seen[raindrop.ID] = raindrop.LastUpdate
+		kept = append(kept, raindrop)
+	}
+	return kept
+}
+
+// trackingParams are query parameters that only record where a click came
+// from, they are ignored when comparing links. Any utm_ parameter is too
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"dclid":   true,
+	"msclkid": true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"igshid":  true,
+}
+
+// NormalizeLink returns link with a lowercase scheme and host, without
+// tracking parameters and without a trailing slash, so copies of the same
+// link compare equal. Links that can't be parsed are returned as is
+func NormalizeLink(link string) string {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || u.Host == "" {
+		return link
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = strings.TrimRight(u.RawPath, "/")
+
+	query := u.Query()
+	for key := range query {
+		if trackingParams[strings.ToLower(key)] || strings.HasPrefix(strings.ToLower(key), "utm_") {
+			query.Del(key)
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+// DedupeByURL keeps the earliest created raindrop of every normalized link,
+// returning the kept raindrops in their original order along with the ids of
+// the ones dropped
+func DedupeByURL(raindrops []raindropio.Raindrop) ([]raindropio.Raindrop, []int) {
+	earliest := make(map[string]int, len(raindrops))
+	for i, raindrop := range raindrops {
+		link := NormalizeLink(raindrop.Link)
+		j, seen := earliest[link]
+		if !seen || raindrop.Created.Before(raindrops[j].Created) {
+			earliest[link] = i
+		}
+	}
+
+	kept := make([]raindropio.Raindrop, 0, len(earliest))
+	var dropped []int
+	for i, raindrop := range raindrops {
+		if earliest[NormalizeLink(raindrop.Link)] == i {
+			kept = append(kept, raindrop)
+		} else {
+			dropped = append(dropped, raindrop.ID)
+		}
+	}
+	return kept, dropped
+}
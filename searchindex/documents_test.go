@@ -0,0 +1,146 @@
+package searchindex
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/zpeters/dropsearch/raindropio"
+	"testing"
+	"time"
+)
+
+func TestPrepareDocuments(t *testing.T) {
+	var raindrop raindropio.Raindrop
+	err := json.Unmarshal([]byte(`{
+		"_id": 1,
+		"collection": {"$id": 7},
+		"created": "2024-03-05T10:00:00Z",
+		"note": "read again",
+		"highlights": [{"text": " first quote "}, {"text": ""}, {"text": "second quote"}]
+	}`), &raindrop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raindrops := []raindropio.Raindrop{raindrop}
+
+	PrepareDocuments(raindrops, map[int]string{7: "Go"}, map[int]string{7: "Dev/Go"})
+
+	got := raindrops[0]
+	if got.HighlightText != "first quote\nsecond quote" {
+		t.Errorf("HighlightText = %q, want the highlight texts joined", got.HighlightText)
+	}
+	if got.CollectionID != 7 || got.CollectionTitle != "Go" || got.CollectionPath != "Dev/Go" {
+		t.Errorf("collection fields = %d, %q, %q, want 7, Go and Dev/Go", got.CollectionID, got.CollectionTitle, got.CollectionPath)
+	}
+	if got.CreatedUnix != 1709632800 {
+		t.Errorf("CreatedUnix = %d, want 1709632800", got.CreatedUnix)
+	}
+	for _, field := range []string{"note", "highlight_text"} {
+		if !contains(DefaultSearchableAttributes, field) {
+			t.Errorf("%s isn't searchable by default", field)
+		}
+	}
+}
+
+// contains reports whether list holds value
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCollectionPaths(t *testing.T) {
+	collections := []raindropio.Collection{
+		{ID: 1, Title: "Dev"},
+		{ID: 2, Title: "Go", Parent: &raindropio.Parent{ID: 1}},
+		{ID: 3, Title: "Loop", Parent: &raindropio.Parent{ID: 4}},
+		{ID: 4, Title: "Back", Parent: &raindropio.Parent{ID: 3}},
+	}
+
+	paths := CollectionPaths(collections)
+
+	want := map[int]string{1: "Dev", 2: "Dev/Go", 3: "Back/Loop", 4: "Loop/Back"}
+	for id, path := range want {
+		if paths[id] != path {
+			t.Errorf("path of %d = %q, want %q", id, paths[id], path)
+		}
+	}
+}
+
+func TestDeduperKeepsNewestCopy(t *testing.T) {
+	older := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+	dedupe := NewDeduper()
+
+	first := dedupe.Filter([]raindropio.Raindrop{{ID: 1, LastUpdate: newer}})
+	stale := dedupe.Filter([]raindropio.Raindrop{{ID: 1, LastUpdate: older}})
+
+	if len(first) != 1 || len(stale) != 0 || dedupe.Duplicates != 1 {
+		t.Errorf("kept %d then %d with %d duplicates, want 1, 0 and 1", len(first), len(stale), dedupe.Duplicates)
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	got := NormalizeTags([]string{"Go", " go ", "", "Web"})
+	if fmt.Sprint(got) != "[go web]" {
+		t.Errorf("NormalizeTags = %v, want [go web]", got)
+	}
+}
+
+func TestFaviconURL(t *testing.T) {
+	service := "https://icons.example.com/{domain}.ico?fallback={domain}"
+	tests := []struct {
+		service string
+		domain  string
+		want    string
+	}{
+		{service, "go.dev", "https://icons.example.com/go.dev.ico?fallback=go.dev"},
+		{service, "a b&c", "https://icons.example.com/a+b%26c.ico?fallback=a+b%26c"},
+		{service, "", ""},
+		{"", "go.dev", ""},
+	}
+	for _, test := range tests {
+		if got := FaviconURL(test.service, test.domain); got != test.want {
+			t.Errorf("FaviconURL(%q, %q) = %q, want %q", test.service, test.domain, got, test.want)
+		}
+	}
+}
+
+func TestNormalizeLink(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+	}{
+		{"HTTPS://Example.COM/Path/", "https://example.com/Path"},
+		{"https://example.com/a?utm_source=x&id=3&fbclid=y", "https://example.com/a?id=3"},
+		{" https://example.com ", "https://example.com"},
+		{"not a link", "not a link"},
+	}
+	for _, test := range tests {
+		if got := NormalizeLink(test.link); got != test.want {
+			t.Errorf("NormalizeLink(%q) = %q, want %q", test.link, got, test.want)
+		}
+	}
+}
+
+func TestDedupeByURL(t *testing.T) {
+	day := 24 * time.Hour
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	raindrops := []raindropio.Raindrop{
+		{ID: 1, Link: "https://example.com/a/", Created: start.Add(2 * day)},
+		{ID: 2, Link: "https://example.com/b", Created: start},
+		{ID: 3, Link: "https://EXAMPLE.com/a?utm_medium=feed", Created: start.Add(day)},
+	}
+
+	kept, dropped := DedupeByURL(raindrops)
+
+	var keptIDs []int
+	for _, raindrop := range kept {
+		keptIDs = append(keptIDs, raindrop.ID)
+	}
+	if fmt.Sprint(keptIDs) != "[2 3]" || fmt.Sprint(dropped) != "[1]" {
+		t.Errorf("kept %v and dropped %v, want the earliest copy [2 3] kept and [1] dropped", keptIDs, dropped)
+	}
+}
@@ -0,0 +1,102 @@
+package searchindex_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/meilisearch/meilisearch-go"
+	"github.com/zpeters/dropsearch/raindropio"
+	"github.com/zpeters/dropsearch/searchindex"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// fakeMeilisearch stands in for a meilisearch server holding a single index,
+// returning every document added to it as the hits of any search
+func fakeMeilisearch() *httptest.Server {
+	var (
+		mu        sync.Mutex
+		documents []json.RawMessage
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/indexes/raindrops", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"uid": "raindrops", "primaryKey": "_id"}`)
+	})
+	mux.HandleFunc("/indexes/raindrops/documents", func(w http.ResponseWriter, r *http.Request) {
+		var batch []json.RawMessage
+		json.NewDecoder(r.Body).Decode(&batch)
+		mu.Lock()
+		documents = append(documents, batch...)
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprint(w, `{"taskUid": 1, "indexUid": "raindrops", "status": "enqueued"}`)
+	})
+	mux.HandleFunc("/indexes/raindrops/settings/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprint(w, `{"taskUid": 1, "indexUid": "raindrops", "status": "enqueued"}`)
+	})
+	mux.HandleFunc("/tasks/1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"uid": 1, "indexUid": "raindrops", "status": "succeeded"}`)
+	})
+	mux.HandleFunc("/indexes/raindrops/search", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"hits":               documents,
+			"estimatedTotalHits": len(documents),
+		})
+	})
+	return httptest.NewServer(mux)
+}
+
+func Example() {
+	server := fakeMeilisearch()
+	defer server.Close()
+
+	ctx := context.Background()
+	client := meilisearch.NewClient(meilisearch.ClientConfig{Host: server.URL})
+
+	// raindrops would normally come from raindropio.Client.RaindropsInCollection
+	collections := []raindropio.Collection{{ID: 1, Title: "Reading"}}
+	raindrops := []raindropio.Raindrop{
+		{ID: 10, Title: "Effective Go", Link: "https://go.dev/doc/effective_go"},
+	}
+	raindrops[0].Collection.ID = 1
+	searchindex.PrepareDocuments(raindrops, searchindex.CollectionTitles(collections), searchindex.CollectionPaths(collections))
+
+	err := searchindex.EnsureIndex(ctx, client, "raindrops")
+	if err == nil {
+		// without the filterable attributes, searches by tag or collection fail
+		err = searchindex.Configure(client.Index("raindrops"), searchindex.Settings{
+			MinWordSizeOneTypo:  searchindex.DefaultMinWordSizeOneTypo,
+			MinWordSizeTwoTypos: searchindex.DefaultMinWordSizeTwoTypos,
+		})
+	}
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	sender := searchindex.NewSender(client, "raindrops", 100)
+	err = sender.Add(ctx, raindrops)
+	if err == nil {
+		err = sender.Flush(ctx)
+	}
+	if err == nil {
+		err = sender.Wait(ctx)
+	}
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	result, err := searchindex.Search(client.Index("raindrops"), "effective", searchindex.Query{Limit: 10, Collections: []int{1}})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, hit := range result.Hits {
+		fmt.Printf("%s (%s) in %s\n", hit.Title, hit.Link, hit.CollectionTitle)
+	}
+	// Output: Effective Go (https://go.dev/doc/effective_go) in Reading
+}
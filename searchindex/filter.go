@@ -0,0 +1,136 @@
+package searchindex
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RaindropTypes are the bookmark types Raindrop assigns
+var RaindropTypes = []string{"link", "article", "image", "video", "document", "audio"}
+
+// CacheStatuses are the states Raindrop reports for its cached copy of a page
+var CacheStatuses = []string{"ready", "retry", "failed", "invalid-origin", "invalid-timeout", "invalid-size"}
+
+// quoteFilterValue quotes a string for use in a meilisearch filter expression
+func quoteFilterValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return `"` + value + `"`
+}
+
+// tagFilter builds a filter matching bookmarks that have all of the tags, or
+// any of them with matchAny set
+func tagFilter(tags []string, matchAny bool) string {
+	clauses := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		clauses = append(clauses, fmt.Sprintf("tags = %s", quoteFilterValue(tag)))
+	}
+	if !matchAny {
+		return strings.Join(clauses, " AND ")
+	}
+	if len(clauses) == 1 {
+		return clauses[0]
+	}
+	return "(" + strings.Join(clauses, " OR ") + ")"
+}
+
+// collectionFilter builds a filter matching bookmarks in any of the
+// collections. Ids of 0 are ignored, so no ids means no filter
+func collectionFilter(collectionIDs []int) string {
+	var ids []string
+	for _, id := range collectionIDs {
+		if id != 0 {
+			ids = append(ids, strconv.Itoa(id))
+		}
+	}
+
+	switch len(ids) {
+	case 0:
+		return ""
+	case 1:
+		return "collection_id = " + ids[0]
+	default:
+		return "collection_id IN [" + strings.Join(ids, ", ") + "]"
+	}
+}
+
+// domainFilter builds a filter matching bookmarks saved from the domain
+func domainFilter(domain string) string {
+	if domain == "" {
+		return ""
+	}
+	return fmt.Sprintf("domain = %s", quoteFilterValue(domain))
+}
+
+// TypeFilter builds a filter matching bookmarks of the given type, failing
+// for types Raindrop doesn't use
+func TypeFilter(raindropType string) (string, error) {
+	if raindropType == "" {
+		return "", nil
+	}
+	for _, known := range RaindropTypes {
+		if raindropType == known {
+			return fmt.Sprintf("type = %s", quoteFilterValue(raindropType)), nil
+		}
+	}
+	return "", fmt.Errorf("unknown type %q, use one of %s", raindropType, strings.Join(RaindropTypes, ", "))
+}
+
+// CacheStatusFilter builds a filter matching bookmarks whose cached copy is
+// in the given state, failing for states Raindrop doesn't use
+func CacheStatusFilter(status string) (string, error) {
+	if status == "" {
+		return "", nil
+	}
+	for _, known := range CacheStatuses {
+		if status == known {
+			return fmt.Sprintf("cache.status = %s", quoteFilterValue(status)), nil
+		}
+	}
+	return "", fmt.Errorf("unknown cache status %q, use one of %s", status, strings.Join(CacheStatuses, ", "))
+}
+
+// dateFilter builds a filter on the created date, a zero time leaves that
+// end of the range open
+func dateFilter(after time.Time, before time.Time) string {
+	var clauses []string
+	if !after.IsZero() {
+		clauses = append(clauses, fmt.Sprintf("created_unix >= %d", after.Unix()))
+	}
+	if !before.IsZero() {
+		clauses = append(clauses, fmt.Sprintf("created_unix <= %d", before.Unix()))
+	}
+	return strings.Join(clauses, " AND ")
+}
+
+// Filter combines the filters requested in the query into a single
+// expression, returning an empty string when there is nothing to filter.
+// Unknown types and cache statuses are left out, check them with TypeFilter
+// and CacheStatusFilter first
+func (q Query) Filter() string {
+	var clauses []string
+	if len(q.Tags) > 0 {
+		clauses = append(clauses, tagFilter(q.Tags, q.AnyTag))
+	}
+	if filter := collectionFilter(q.Collections); filter != "" {
+		clauses = append(clauses, filter)
+	}
+	if filter := dateFilter(q.After, q.Before); filter != "" {
+		clauses = append(clauses, filter)
+	}
+	if filter := domainFilter(q.Domain); filter != "" {
+		clauses = append(clauses, filter)
+	}
+	if filter, _ := TypeFilter(q.Type); filter != "" {
+		clauses = append(clauses, filter)
+	}
+	if filter, _ := CacheStatusFilter(q.CacheStatus); filter != "" {
+		clauses = append(clauses, filter)
+	}
+	if q.Important {
+		clauses = append(clauses, "important = true")
+	}
+	return strings.Join(clauses, " AND ")
+}
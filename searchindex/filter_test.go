@@ -0,0 +1,53 @@
+package searchindex
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueryFilter(t *testing.T) {
+	after := time.Unix(1700000000, 0)
+	before := time.Unix(1710000000, 0)
+	tests := []struct {
+		name  string
+		query Query
+		want  string
+	}{
+		{"nothing", Query{}, ""},
+		{"one tag", Query{Tags: []string{"go"}}, `tags = "go"`},
+		{"all tags", Query{Tags: []string{"go", "web"}}, `tags = "go" AND tags = "web"`},
+		{"quoted tag", Query{Tags: []string{`say "hi"\`}}, `tags = "say \"hi\"\\"`},
+		{"one collection", Query{Collections: []int{12}}, "collection_id = 12"},
+		{"collections", Query{Collections: []int{12, 0, 34}}, "collection_id IN [12, 34]"},
+		{"unsorted collection only", Query{Collections: []int{0}}, ""},
+		{"after", Query{After: after}, "created_unix >= 1700000000"},
+		{"range", Query{After: after, Before: before}, "created_unix >= 1700000000 AND created_unix <= 1710000000"},
+		{"domain", Query{Domain: "go.dev"}, `domain = "go.dev"`},
+		{"any tag", Query{Tags: []string{"go", "web"}, AnyTag: true}, `(tags = "go" OR tags = "web")`},
+		{"type", Query{Type: "video"}, `type = "video"`},
+		{"unknown type", Query{Type: "podcast"}, ""},
+		{"cache status", Query{CacheStatus: "failed"}, `cache.status = "failed"`},
+		{"unknown cache status", Query{CacheStatus: "stale"}, ""},
+		{"important", Query{Important: true}, "important = true"},
+		{"important tag", Query{Tags: []string{"go"}, Important: true}, `tags = "go" AND important = true`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.query.Filter(); got != test.want {
+				t.Errorf("Filter() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestTypeFilterRejectsUnknownTypes(t *testing.T) {
+	if _, err := TypeFilter("podcast"); err == nil {
+		t.Error("TypeFilter should reject types Raindrop doesn't use")
+	}
+}
+
+func TestCacheStatusFilterRejectsUnknownStatuses(t *testing.T) {
+	if _, err := CacheStatusFilter("stale"); err == nil {
+		t.Error("CacheStatusFilter should reject statuses Raindrop doesn't use")
+	}
+}
@@ -0,0 +1,130 @@
+package searchindex
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/meilisearch/meilisearch-go"
+	"github.com/zpeters/dropsearch/raindropio"
+	"log/slog"
+	"strings"
+)
+
+// HighlightsPrimaryKey is named explicitly since parent_id would also be a
+// candidate for meilisearch to guess
+const HighlightsPrimaryKey = "id"
+
+// HighlightDocument is a single highlight indexed on its own, pointing back
+// at the bookmark it was made in
+type HighlightDocument struct {
+	ID       string `json:"id"`
+	ParentID int    `json:"parent_id"`
+	Text     string `json:"text"`
+	Note     string `json:"note"`
+	Link     string `json:"link"`
+	Title    string `json:"title"`
+}
+
+// HighlightDocuments returns a document for every highlight of the
+// raindrops. Highlights without an id, like imported ones, are numbered
+// within their bookmark instead
+func HighlightDocuments(raindrops []raindropio.Raindrop) []HighlightDocument {
+	var documents []HighlightDocument
+	for _, raindrop := range raindrops {
+		for i, highlight := range raindrop.Highlights {
+			if strings.TrimSpace(highlight.Text) == "" {
+				continue
+			}
+			id := highlight.ID
+			if id == "" {
+				id = fmt.Sprintf("%d-%d", raindrop.ID, i)
+			}
+			documents = append(documents, HighlightDocument{
+				ID:       id,
+				ParentID: raindrop.ID,
+				Text:     highlight.Text,
+				Note:     highlight.Note,
+				Link:     raindrop.Link,
+				Title:    raindrop.Title,
+			})
+		}
+	}
+	return documents
+}
+
+// IndexHighlights adds the highlight documents to their own index in batches
+// of batchSize, retrying transient errors retries times, and waits for
+// meilisearch to process them
+func IndexHighlights(ctx context.Context, client *meilisearch.Client, indexName string, documents []HighlightDocument, batchSize int, retries int) error {
+	index := client.Index(indexName)
+	_, err := index.UpdateSearchableAttributes(&[]string{"text", "note", "title"})
+	if err != nil {
+		return fmt.Errorf("error updating highlights searchable attributes: %w", err)
+	}
+	_, err = index.UpdateFilterableAttributes(&[]string{"parent_id"})
+	if err != nil {
+		return fmt.Errorf("error updating highlights filterable attributes: %w", err)
+	}
+
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	var tasks []*meilisearch.TaskInfo
+	for start := 0; start < len(documents); start += batchSize {
+		end := start + batchSize
+		if end > len(documents) {
+			end = len(documents)
+		}
+
+		var taskInfo *meilisearch.TaskInfo
+		err := retryTransient(ctx, retries, func() error {
+			var err error
+			taskInfo, err = index.AddDocuments(documents[start:end], HighlightsPrimaryKey)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("error adding highlights: %w", err)
+		}
+		tasks = append(tasks, taskInfo)
+	}
+
+	for _, taskInfo := range tasks {
+		err = waitForTask(ctx, client, taskInfo)
+		if err != nil {
+			return err
+		}
+	}
+
+	slog.Info("highlights indexed", "index", indexName, "count", len(documents))
+	return nil
+}
+
+// SearchHighlights searches a highlights index, returning the matching
+// highlights and meilisearch's estimate of the total. Only the Limit, Offset
+// and Match of the query apply to highlights
+func SearchHighlights(index *meilisearch.Index, q string, query Query) ([]HighlightDocument, int64, error) {
+	searchResult, err := index.Search(q, &meilisearch.SearchRequest{
+		Limit:            query.Limit,
+		Offset:           query.Offset,
+		MatchingStrategy: query.Match,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	hits := make([]HighlightDocument, 0, len(searchResult.Hits))
+	for _, rawHit := range searchResult.Hits {
+		hitBytes, err := json.Marshal(rawHit)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error marshalling hit: %w", err)
+		}
+		var hit HighlightDocument
+		err = json.Unmarshal(hitBytes, &hit)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error unmarshalling hit: %w", err)
+		}
+		hits = append(hits, hit)
+	}
+	return hits, searchResult.EstimatedTotalHits, nil
+}
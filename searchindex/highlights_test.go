@@ -0,0 +1,36 @@
+package searchindex
+
+import (
+	"encoding/json"
+	"github.com/zpeters/dropsearch/raindropio"
+	"testing"
+)
+
+func TestHighlightDocuments(t *testing.T) {
+	var raindrops []raindropio.Raindrop
+	err := json.Unmarshal([]byte(`[
+		{"_id": 7, "title": "Effective Go", "link": "https://go.dev/doc/effective_go", "highlights": [
+			{"_id": "h1", "text": "Don't panic", "note": "errors"},
+			{"text": "Gofmt formats", "note": ""},
+			{"_id": "h3", "text": "  "}
+		]},
+		{"_id": 8, "title": "No highlights"}
+	]`), &raindrops)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	documents := HighlightDocuments(raindrops)
+	want := []HighlightDocument{
+		{ID: "h1", ParentID: 7, Text: "Don't panic", Note: "errors", Link: "https://go.dev/doc/effective_go", Title: "Effective Go"},
+		{ID: "7-1", ParentID: 7, Text: "Gofmt formats", Link: "https://go.dev/doc/effective_go", Title: "Effective Go"},
+	}
+	if len(documents) != len(want) {
+		t.Fatalf("got %d documents, want one for each of the two highlights with text: %+v", len(documents), documents)
+	}
+	for i := range want {
+		if documents[i] != want[i] {
+			t.Errorf("document %d = %+v, want %+v", i, documents[i], want[i])
+		}
+	}
+}
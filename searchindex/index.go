@@ -0,0 +1,281 @@
+// Package searchindex indexes raindrops in Meilisearch and searches them. It
+// holds the indexing and search logic of dropsearch so other programs can
+// build on it, with raindrops fetched by the raindropio package
+package searchindex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/meilisearch/meilisearch-go"
+	"github.com/zpeters/dropsearch/raindropio"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// PrimaryKey is the document field meilisearch identifies bookmarks by
+const PrimaryKey = "_id"
+
+// ErrWrongPrimaryKey is returned by EnsureIndex for an index whose primary
+// key isn't PrimaryKey, which can only be fixed by recreating the index
+var ErrWrongPrimaryKey = errors.New("wrong primary key")
+
+// taskPollInterval is how often meilisearch is asked about a pending task
+const taskPollInterval = 250 * time.Millisecond
+
+// Sender buffers documents and sends them to meilisearch in batches, keeping
+// the tasks to wait for. In a dry run batches are only counted
+type Sender struct {
+	// DryRun counts the documents without sending them
+	DryRun bool
+	// Retries is how many times a batch is resent after a transient
+	// meilisearch error
+	Retries int
+
+	client    *meilisearch.Client
+	index     *meilisearch.Index
+	batchSize int
+	pending   []raindropio.Raindrop
+	tasks     []*meilisearch.TaskInfo
+	sent      int
+}
+
+// NewSender returns a Sender adding documents to the index in batches of
+// batchSize
+func NewSender(client *meilisearch.Client, indexName string, batchSize int) *Sender {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	return &Sender{
+		client:    client,
+		index:     client.Index(indexName),
+		batchSize: batchSize,
+	}
+}
+
+// Add queues the documents, sending every full batch
+func (s *Sender) Add(ctx context.Context, documents []raindropio.Raindrop) error {
+	s.pending = append(s.pending, documents...)
+	for len(s.pending) >= s.batchSize {
+		err := s.send(ctx, s.pending[:s.batchSize])
+		if err != nil {
+			return err
+		}
+		s.pending = s.pending[s.batchSize:]
+	}
+
+	return nil
+}
+
+// Flush sends any queued documents that didn't fill a batch
+func (s *Sender) Flush(ctx context.Context) error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	err := s.send(ctx, s.pending)
+	if err != nil {
+		return err
+	}
+	s.pending = nil
+	return nil
+}
+
+// Sent is the number of documents sent so far, or counted in a dry run
+func (s *Sender) Sent() int {
+	return s.sent
+}
+
+// Wait blocks until meilisearch has processed every batch sent
+func (s *Sender) Wait(ctx context.Context) error {
+	for _, taskInfo := range s.tasks {
+		err := waitForTask(ctx, s.client, taskInfo)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Sender) send(ctx context.Context, batch []raindropio.Raindrop) error {
+	if s.DryRun {
+		s.sent += len(batch)
+		return nil
+	}
+
+	var taskInfo *meilisearch.TaskInfo
+	err := retryTransient(ctx, s.Retries, func() error {
+		var err error
+		taskInfo, err = s.index.AddDocuments(batch, PrimaryKey)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error adding batch %d: %w", len(s.tasks)+1, err)
+	}
+	s.tasks = append(s.tasks, taskInfo)
+	s.sent += len(batch)
+	slog.Info("sent batch", "batch", len(s.tasks), "documents", len(batch), "task", taskInfo.TaskUID)
+	return nil
+}
+
+// retryTransient calls fn until it succeeds, fails with an error that isn't
+// transient or has been retried retries times, backing off the same way as
+// Raindrop requests. Cancelling ctx stops the backoff early
+func retryTransient(ctx context.Context, retries int, fn func() error) error {
+	backoff := raindropio.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= retries || !isTransientMeilisearchError(err) {
+			return err
+		}
+		slog.Warn("meilisearch request failed, retrying", "error", err, slog.String("retry_in", backoff.String()))
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// isTransientMeilisearchError reports whether err could go away by retrying,
+// meilisearch being unreachable, timing out, overloaded or failing
+// internally. Errors like an invalid API key are permanent
+func isTransientMeilisearchError(err error) bool {
+	var apiErr *meilisearch.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.ErrCode {
+	case meilisearch.MeilisearchCommunicationError, meilisearch.MeilisearchTimeoutError:
+		return true
+	}
+	return apiErr.StatusCode >= http.StatusInternalServerError || apiErr.StatusCode == http.StatusTooManyRequests
+}
+
+// waitForTask blocks until meilisearch has finished the task, returning an
+// error with meilisearch's reason if it didn't succeed
+func waitForTask(ctx context.Context, client *meilisearch.Client, taskInfo *meilisearch.TaskInfo) error {
+	task, err := client.WaitForTask(taskInfo.TaskUID, meilisearch.WaitParams{
+		Context:  ctx,
+		Interval: taskPollInterval,
+	})
+	if err != nil {
+		return fmt.Errorf("error waiting for task %d: %w", taskInfo.TaskUID, err)
+	}
+
+	switch task.Status {
+	case meilisearch.TaskStatusSucceeded:
+		return nil
+	case meilisearch.TaskStatusFailed:
+		return fmt.Errorf("task %d failed: %s (%s)", task.UID, task.Error.Message, task.Error.Code)
+	default:
+		return fmt.Errorf("task %d finished with status '%s'", task.UID, task.Status)
+	}
+}
+
+// ResetIndex deletes the index if it exists and creates it again empty, with
+// PrimaryKey set so it doesn't have to be guessed
+func ResetIndex(ctx context.Context, client *meilisearch.Client, indexName string) error {
+	_, err := client.GetIndex(indexName)
+	if err != nil && !IsIndexNotFound(err) {
+		return fmt.Errorf("error getting index: %w", err)
+	}
+	if err == nil {
+		taskInfo, err := client.DeleteIndex(indexName)
+		if err != nil {
+			return fmt.Errorf("error deleting index: %w", err)
+		}
+		err = waitForTask(ctx, client, taskInfo)
+		if err != nil {
+			return err
+		}
+	}
+
+	return createIndex(ctx, client, indexName)
+}
+
+// EnsureIndex creates the index with PrimaryKey if it doesn't exist yet, so
+// meilisearch never guesses the primary key from fields like collection_id.
+// An existing index with a different primary key can't be changed in place
+func EnsureIndex(ctx context.Context, client *meilisearch.Client, indexName string) error {
+	index, err := client.GetIndex(indexName)
+	if IsIndexNotFound(err) {
+		return createIndex(ctx, client, indexName)
+	}
+	if err != nil {
+		return fmt.Errorf("error getting index: %w", err)
+	}
+
+	if index.PrimaryKey != "" && index.PrimaryKey != PrimaryKey {
+		return fmt.Errorf("%w: index %q uses %s instead of %s", ErrWrongPrimaryKey, indexName, index.PrimaryKey, PrimaryKey)
+	}
+	return nil
+}
+
+// createIndex creates the index with PrimaryKey and waits for meilisearch to
+// finish. An index created in the meantime by someone else is fine
+func createIndex(ctx context.Context, client *meilisearch.Client, indexName string) error {
+	taskInfo, err := client.CreateIndex(&meilisearch.IndexConfig{
+		Uid:        indexName,
+		PrimaryKey: PrimaryKey,
+	})
+	if err != nil {
+		return fmt.Errorf("error creating index: %w", err)
+	}
+
+	task, err := client.WaitForTask(taskInfo.TaskUID, meilisearch.WaitParams{
+		Context:  ctx,
+		Interval: taskPollInterval,
+	})
+	if err != nil {
+		return fmt.Errorf("error waiting for task %d: %w", taskInfo.TaskUID, err)
+	}
+	if task.Status == meilisearch.TaskStatusFailed && task.Error.Code != "index_already_exists" {
+		return fmt.Errorf("error creating index: %s (%s)", task.Error.Message, task.Error.Code)
+	}
+
+	slog.Info("created index", "index", indexName, "primary_key", PrimaryKey)
+	return nil
+}
+
+// DeleteDocuments removes the documents with the given ids from the index and
+// waits for meilisearch to finish
+func DeleteDocuments(ctx context.Context, client *meilisearch.Client, indexName string, ids []int) error {
+	identifiers := make([]string, 0, len(ids))
+	for _, id := range ids {
+		identifiers = append(identifiers, strconv.Itoa(id))
+	}
+
+	taskInfo, err := client.Index(indexName).DeleteDocuments(identifiers)
+	if err != nil {
+		return fmt.Errorf("error deleting documents: %w", err)
+	}
+	return waitForTask(ctx, client, taskInfo)
+}
+
+// IsIndexNotFound reports whether err is meilisearch saying the index doesn't
+// exist
+func IsIndexNotFound(err error) bool {
+	var apiErr *meilisearch.Error
+	return errors.As(err, &apiErr) && apiErr.MeilisearchApiError.Code == "index_not_found"
+}
+
+// isInvalidSort reports whether err is meilisearch rejecting the sort rules,
+// like sorting on an attribute that isn't sortable
+func isInvalidSort(err error) bool {
+	var apiErr *meilisearch.Error
+	return errors.As(err, &apiErr) && apiErr.MeilisearchApiError.Code == "invalid_search_sort"
+}
+
+// IndexIsEmpty reports whether the index holds no documents, which tells an
+// index that was never filled apart from a search without matches. Errors
+// fetching the stats count as not empty
+func IndexIsEmpty(index *meilisearch.Index) bool {
+	stats, err := index.GetStats()
+	if err != nil {
+		return false
+	}
+	return stats.NumberOfDocuments == 0
+}
@@ -0,0 +1,313 @@
+package searchindex
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/meilisearch/meilisearch-go"
+	"github.com/zpeters/dropsearch/raindropio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeRequest is a request received by the fake meilisearch
+type fakeRequest struct {
+	Method string
+	Path   string
+	Query  string
+	Body   string
+}
+
+// newFakeMeilisearch returns a client for a test server answering each
+// "METHOD /path" with its handler and recording every request. Requests
+// without a handler get meilisearch's not found error
+func newFakeMeilisearch(t *testing.T, routes map[string]http.HandlerFunc) (*meilisearch.Client, *[]fakeRequest) {
+	t.Helper()
+	var requests []fakeRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, fakeRequest{Method: r.Method, Path: r.URL.Path, Query: r.URL.RawQuery, Body: string(body)})
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		handler, ok := routes[r.Method+" "+r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "not found", "code": "not_found", "type": "invalid_request"}`)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return meilisearch.NewClient(meilisearch.ClientConfig{Host: server.URL}), &requests
+}
+
+// enqueued answers with a task the way meilisearch accepts asynchronous work
+func enqueued(uid int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintf(w, `{"taskUid": %d, "status": "enqueued"}`, uid)
+	}
+}
+
+// taskStatus answers a task lookup with the task's final state
+func taskStatus(uid int, status string, errorCode string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"uid": %d, "status": %q, "error": {"message": "task went wrong", "code": %q}}`, uid, status, errorCode)
+	}
+}
+
+func TestWaitForTask(t *testing.T) {
+	client, _ := newFakeMeilisearch(t, map[string]http.HandlerFunc{
+		"GET /tasks/1": taskStatus(1, "succeeded", ""),
+		"GET /tasks/2": taskStatus(2, "failed", "missing_document_id"),
+	})
+	ctx := context.Background()
+
+	err := waitForTask(ctx, client, &meilisearch.TaskInfo{TaskUID: 1})
+	if err != nil {
+		t.Errorf("succeeded task returned %v", err)
+	}
+
+	err = waitForTask(ctx, client, &meilisearch.TaskInfo{TaskUID: 2})
+	if err == nil || err.Error() != "task 2 failed: task went wrong (missing_document_id)" {
+		t.Errorf("failed task returned %v, want meilisearch's reason", err)
+	}
+}
+
+func TestSenderBatches(t *testing.T) {
+	client, requests := newFakeMeilisearch(t, map[string]http.HandlerFunc{
+		"POST /indexes/raindrops/documents": enqueued(1),
+	})
+	raindrops := make([]raindropio.Raindrop, 2500)
+	for i := range raindrops {
+		raindrops[i].ID = i + 1
+	}
+
+	sender := NewSender(client, "raindrops", 1000)
+	err := sender.Add(context.Background(), raindrops[:1200])
+	if err == nil {
+		err = sender.Add(context.Background(), raindrops[1200:])
+	}
+	if err == nil {
+		err = sender.Flush(context.Background())
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sizes []int
+	for _, request := range *requests {
+		if request.Query != "primaryKey=_id" {
+			t.Errorf("sent a batch with query %q, want the primary key set", request.Query)
+		}
+		var batch []raindropio.Raindrop
+		err := json.Unmarshal([]byte(request.Body), &batch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizes = append(sizes, len(batch))
+	}
+	if fmt.Sprint(sizes) != "[1000 1000 500]" {
+		t.Errorf("sent batches of %v, want [1000 1000 500]", sizes)
+	}
+	if sender.Sent() != 2500 {
+		t.Errorf("Sent() = %d, want 2500", sender.Sent())
+	}
+}
+
+func TestSenderDryRun(t *testing.T) {
+	client, requests := newFakeMeilisearch(t, nil)
+
+	sender := NewSender(client, "raindrops", 2)
+	sender.DryRun = true
+	err := sender.Add(context.Background(), make([]raindropio.Raindrop, 5))
+	if err == nil {
+		err = sender.Flush(context.Background())
+	}
+	if err == nil {
+		err = sender.Wait(context.Background())
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*requests) != 0 || sender.Sent() != 5 {
+		t.Errorf("made %d requests and counted %d, want no requests and 5 counted", len(*requests), sender.Sent())
+	}
+}
+
+func TestRetryTransient(t *testing.T) {
+	transient := &meilisearch.Error{StatusCode: http.StatusServiceUnavailable}
+	permanent := &meilisearch.Error{StatusCode: http.StatusUnauthorized}
+
+	calls := 0
+	err := retryTransient(context.Background(), 3, func() error {
+		calls++
+		if calls == 1 {
+			return transient
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Errorf("transient error then success gave %v after %d calls, want nil after 2", err, calls)
+	}
+
+	calls = 0
+	err = retryTransient(context.Background(), 3, func() error {
+		calls++
+		return permanent
+	})
+	if !errors.Is(err, permanent) || calls != 1 {
+		t.Errorf("permanent error gave %v after %d calls, want it returned after 1", err, calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls = 0
+	start := time.Now()
+	err = retryTransient(ctx, 3, func() error {
+		calls++
+		return transient
+	})
+	if !errors.Is(err, context.Canceled) || calls != 1 || time.Since(start) >= raindropio.RetryBackoff {
+		t.Errorf("cancelled context gave %v after %d calls, want context.Canceled without waiting", err, calls)
+	}
+}
+
+// existingIndex answers an index lookup with an index using primaryKey
+func existingIndex(primaryKey string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"uid": "raindrops", "primaryKey": %q}`, primaryKey)
+	}
+}
+
+// indexNotFound answers an index lookup the way meilisearch does for a
+// missing index
+func indexNotFound(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNotFound)
+	fmt.Fprint(w, `{"message": "index not found", "code": "index_not_found", "type": "invalid_request"}`)
+}
+
+// requestLines returns the "METHOD /path" of every request in order
+func requestLines(requests []fakeRequest) []string {
+	var lines []string
+	for _, request := range requests {
+		lines = append(lines, request.Method+" "+request.Path)
+	}
+	return lines
+}
+
+func TestResetIndex(t *testing.T) {
+	tests := []struct {
+		name   string
+		routes map[string]http.HandlerFunc
+		want   []string
+	}{
+		{
+			"existing index",
+			map[string]http.HandlerFunc{
+				"GET /indexes/raindrops":    existingIndex("_id"),
+				"DELETE /indexes/raindrops": enqueued(1),
+				"GET /tasks/1":              taskStatus(1, "succeeded", ""),
+				"POST /indexes":             enqueued(2),
+				"GET /tasks/2":              taskStatus(2, "succeeded", ""),
+			},
+			[]string{"GET /indexes/raindrops", "DELETE /indexes/raindrops", "GET /tasks/1", "POST /indexes", "GET /tasks/2"},
+		},
+		{
+			"missing index",
+			map[string]http.HandlerFunc{
+				"GET /indexes/raindrops": indexNotFound,
+				"POST /indexes":          enqueued(2),
+				"GET /tasks/2":           taskStatus(2, "succeeded", ""),
+			},
+			[]string{"GET /indexes/raindrops", "POST /indexes", "GET /tasks/2"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, requests := newFakeMeilisearch(t, test.routes)
+			err := ResetIndex(context.Background(), client, "raindrops")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := requestLines(*requests); fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("sent %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestEnsureIndexCreatesWithPrimaryKey(t *testing.T) {
+	client, requests := newFakeMeilisearch(t, map[string]http.HandlerFunc{
+		"GET /indexes/raindrops": indexNotFound,
+		"POST /indexes":          enqueued(1),
+		"GET /tasks/1":           taskStatus(1, "succeeded", ""),
+	})
+	err := EnsureIndex(context.Background(), client, "raindrops")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var created map[string]string
+	for _, request := range *requests {
+		if request.Method == http.MethodPost && request.Path == "/indexes" {
+			err := json.Unmarshal([]byte(request.Body), &created)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if created["uid"] != "raindrops" || created["primaryKey"] != "_id" {
+		t.Errorf("created the index with %v, want uid raindrops and primaryKey _id", created)
+	}
+}
+
+func TestEnsureIndex(t *testing.T) {
+	tests := []struct {
+		name    string
+		routes  map[string]http.HandlerFunc
+		wantErr error
+	}{
+		{"existing index", map[string]http.HandlerFunc{
+			"GET /indexes/raindrops": existingIndex("_id"),
+		}, nil},
+		{"existing index without documents", map[string]http.HandlerFunc{
+			"GET /indexes/raindrops": existingIndex(""),
+		}, nil},
+		{"wrong primary key", map[string]http.HandlerFunc{
+			"GET /indexes/raindrops": existingIndex("id"),
+		}, ErrWrongPrimaryKey},
+		{"created in the meantime", map[string]http.HandlerFunc{
+			"GET /indexes/raindrops": indexNotFound,
+			"POST /indexes":          enqueued(1),
+			"GET /tasks/1":           taskStatus(1, "failed", "index_already_exists"),
+		}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, _ := newFakeMeilisearch(t, test.routes)
+			err := EnsureIndex(context.Background(), client, "raindrops")
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("got %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestEnsureIndexCreateFails(t *testing.T) {
+	client, _ := newFakeMeilisearch(t, map[string]http.HandlerFunc{
+		"GET /indexes/raindrops": indexNotFound,
+		"POST /indexes":          enqueued(1),
+		"GET /tasks/1":           taskStatus(1, "failed", "invalid_index_uid"),
+	})
+	err := EnsureIndex(context.Background(), client, "raindrops")
+	if err == nil || err.Error() != "error creating index: task went wrong (invalid_index_uid)" {
+		t.Errorf("got %v, want meilisearch's reason", err)
+	}
+}
@@ -0,0 +1,276 @@
+package searchindex
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/meilisearch/meilisearch-go"
+	"github.com/zpeters/dropsearch/raindropio"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+)
+
+// highlightCropLength is the number of words kept around a match when
+// showing a snippet of a highlight
+const highlightCropLength = 20
+
+// searchPageSize is how many hits each request fetches while paging through
+// every result for a Limit of 0
+const searchPageSize = 100
+
+// DefaultMaxResults is how many hits a Limit of 0 fetches at most when
+// MaxResults isn't set
+const DefaultMaxResults = 10000
+
+// tiebreakSort orders equally relevant hits newest first. Meilisearch applies
+// sort rules after ranking on words, typos, proximity and attributes, so
+// relevance still decides the order first
+var tiebreakSort = []string{"created_unix:desc"}
+
+// HighlightPreTag and HighlightPostTag surround matched terms in formatted
+// fields. Private use characters are used so they can't clash with content
+const (
+	HighlightPreTag  = "\ue000"
+	HighlightPostTag = "\ue001"
+)
+
+// Query describes a search, its page of results and the filters applied
+type Query struct {
+	// Limit is the maximum number of hits returned, 0 pages through every
+	// hit up to MaxResults
+	Limit int64
+	// MaxResults caps how many hits a Limit of 0 fetches, 0 means
+	// DefaultMaxResults
+	MaxResults int64
+	// Offset is the number of hits skipped, for paging through results
+	Offset int64
+	// Tags restricts hits to bookmarks having all of these tags
+	Tags []string
+	// AnyTag makes Tags match bookmarks having any of the tags instead
+	AnyTag bool
+	// Collections restricts hits to these collection ids, empty means any
+	Collections []int
+	// After and Before restrict hits to a range of created dates, zero times
+	// leave the range open
+	After  time.Time
+	Before time.Time
+	// Domain restricts hits to bookmarks from a single domain
+	Domain string
+	// Type restricts hits to a single bookmark type, like video
+	Type string
+	// CacheStatus restricts hits to bookmarks whose cached copy is in this
+	// state, like failed
+	CacheStatus string
+	// Sort holds meilisearch sort rules, empty means relevance order alone
+	Sort []string
+	// Tiebreak orders equally relevant hits newest first when Sort is empty,
+	// as long as the index has created_unix sortable
+	Tiebreak bool
+	// Match is the matching strategy for multi-word queries, last or all,
+	// empty uses meilisearch's default
+	Match string
+	// RankingScore asks meilisearch for the ranking score of each hit
+	RankingScore bool
+	// MinScore drops hits with a lower ranking score, 0 keeps every hit
+	MinScore float64
+	// Important restricts hits to bookmarks marked as favorites
+	Important bool
+}
+
+// Hit is a raindrop returned by a search along with the extra details
+// meilisearch attaches to each hit
+type Hit struct {
+	raindropio.Raindrop
+	Formatted       map[string]interface{} `json:"_formatted"`
+	MatchesPosition map[string]interface{} `json:"_matchesPosition"`
+	RankingScore    float64                `json:"_rankingScore"`
+	// Source names where the hit came from when searching several indexes,
+	// empty otherwise
+	Source string `json:"-"`
+}
+
+// HighlightSnippet returns the cropped highlight text around the match, or an
+// empty string if the hit didn't match in its highlights
+func (h Hit) HighlightSnippet() string {
+	if _, ok := h.MatchesPosition["highlight_text"]; !ok {
+		return ""
+	}
+	snippet, _ := h.Formatted["highlight_text"].(string)
+	return strings.Join(strings.Fields(snippet), " ")
+}
+
+// MatchedFields returns the sorted names of the fields the query matched in
+func (h Hit) MatchedFields() []string {
+	fields := make([]string, 0, len(h.MatchesPosition))
+	for field := range h.MatchesPosition {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// FormattedField returns the formatted version of a field with its matched
+// terms between HighlightPreTag and HighlightPostTag, falling back to the raw
+// value when meilisearch didn't format it
+func (h Hit) FormattedField(field string, raw string) string {
+	if value, ok := h.Formatted[field].(string); ok {
+		return value
+	}
+	return raw
+}
+
+// Result is a page of hits along with meilisearch's estimate of how many
+// bookmarks matched in total
+type Result struct {
+	Hits               []Hit
+	EstimatedTotalHits int64
+}
+
+// Search runs the query against the index, paging through every hit when
+// query.Limit is 0
+func Search(index *meilisearch.Index, q string, query Query) (*Result, error) {
+	searchResult, err := search(index, q, newSearchRequest(query), query, query.Limit == 0)
+	if err != nil {
+		return nil, err
+	}
+
+	hits, err := decodeHits(searchResult)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{
+		Hits:               withMinScore(hits, query.MinScore),
+		EstimatedTotalHits: searchResult.EstimatedTotalHits,
+	}, nil
+}
+
+// Count returns meilisearch's estimate of how many bookmarks match the query,
+// without fetching them
+func Count(index *meilisearch.Index, q string, query Query) (int64, error) {
+	request := newSearchRequest(query)
+	// only the total is needed, and meilisearch treats a limit of 0 as the default
+	request.Limit = 1
+	searchResult, err := search(index, q, request, query, false)
+	if err != nil {
+		return 0, err
+	}
+	return searchResult.EstimatedTotalHits, nil
+}
+
+// newSearchRequest builds the meilisearch request for the query
+func newSearchRequest(query Query) *meilisearch.SearchRequest {
+	request := &meilisearch.SearchRequest{
+		Limit:                 query.Limit,
+		Offset:                query.Offset,
+		AttributesToCrop:      []string{"highlight_text"},
+		CropLength:            highlightCropLength,
+		AttributesToHighlight: []string{"title", "excerpt", "highlight_text"},
+		HighlightPreTag:       HighlightPreTag,
+		HighlightPostTag:      HighlightPostTag,
+		ShowMatchesPosition:   true,
+		Sort:                  query.Sort,
+		MatchingStrategy:      query.Match,
+		ShowRankingScore:      query.RankingScore || query.MinScore > 0,
+	}
+	if len(request.Sort) == 0 && query.Tiebreak {
+		request.Sort = tiebreakSort
+	}
+	if filter := query.Filter(); filter != "" {
+		request.Filter = filter
+	}
+	return request
+}
+
+// search sends the request, paging through every hit when all is set. An
+// index created before created_unix was made sortable rejects the tiebreak,
+// so the search is repeated in relevance order alone
+func search(index *meilisearch.Index, q string, request *meilisearch.SearchRequest, query Query, all bool) (*meilisearch.SearchResponse, error) {
+	send := func() (*meilisearch.SearchResponse, error) {
+		if all {
+			maxResults := query.MaxResults
+			if maxResults <= 0 {
+				maxResults = DefaultMaxResults
+			}
+			return searchAllPages(index, q, request, maxResults)
+		}
+		return index.Search(q, request)
+	}
+
+	searchResult, err := send()
+	if isInvalidSort(err) && query.Tiebreak && len(query.Sort) == 0 {
+		slog.Debug("index can't sort by created_unix yet, configure it again to enable the tiebreak", "index", index.UID)
+		request.Sort = nil
+		return send()
+	}
+	return searchResult, err
+}
+
+// searchAllPages runs the search a page at a time from request.Offset,
+// collecting hits until the estimated total or maxResults is reached
+func searchAllPages(index *meilisearch.Index, q string, request *meilisearch.SearchRequest, maxResults int64) (*meilisearch.SearchResponse, error) {
+	page := *request
+	var hits []interface{}
+	for {
+		page.Limit = searchPageSize
+		if remaining := maxResults - int64(len(hits)); remaining < page.Limit {
+			page.Limit = remaining
+		}
+
+		result, err := index.Search(q, &page)
+		if err != nil {
+			return nil, err
+		}
+		hits = append(hits, result.Hits...)
+		page.Offset += int64(len(result.Hits))
+
+		done := int64(len(result.Hits)) < page.Limit || page.Offset >= result.EstimatedTotalHits
+		if !done && int64(len(hits)) >= maxResults {
+			slog.Warn("stopped fetching results at the maximum", "max_results", maxResults, "estimated_total", result.EstimatedTotalHits)
+			done = true
+		}
+		if done {
+			result.Hits = hits
+			result.Offset = request.Offset
+			result.Limit = int64(len(hits))
+			return result, nil
+		}
+	}
+}
+
+// decodeHits converts the raw hits of a search response into Hits
+func decodeHits(searchResult *meilisearch.SearchResponse) ([]Hit, error) {
+	hits := make([]Hit, 0, len(searchResult.Hits))
+	for _, rawHit := range searchResult.Hits {
+		hitBytes, err := json.Marshal(rawHit)
+		if err != nil {
+			slog.Warn("error marshalling hit to json", "error", err)
+			continue
+		}
+
+		var hit Hit
+		err = json.Unmarshal(hitBytes, &hit)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling hit: %w", err)
+		}
+		hits = append(hits, hit)
+	}
+	return hits, nil
+}
+
+// withMinScore drops the hits ranked below minScore
+func withMinScore(hits []Hit, minScore float64) []Hit {
+	if minScore <= 0 {
+		return hits
+	}
+	kept := hits[:0]
+	for _, hit := range hits {
+		if hit.RankingScore >= minScore {
+			kept = append(kept, hit)
+		}
+	}
+	if dropped := len(hits) - len(kept); dropped > 0 {
+		slog.Info("dropped results below the minimum score", "count", dropped, "min_score", minScore)
+	}
+	return kept
+}
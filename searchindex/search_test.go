@@ -0,0 +1,148 @@
+package searchindex
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/meilisearch/meilisearch-go"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// searchRequest is the part of a search request body the tests look at
+type searchRequest struct {
+	Limit  int64    `json:"limit"`
+	Offset int64    `json:"offset"`
+	Sort   []string `json:"sort"`
+	Filter string   `json:"filter"`
+}
+
+// newSearchServer serves searches with handle, recording every request
+func newSearchServer(t *testing.T, handle func(w http.ResponseWriter, request searchRequest)) (*meilisearch.Index, *[]searchRequest) {
+	var requests []searchRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request searchRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			t.Errorf("error decoding search request: %v", err)
+		}
+		requests = append(requests, request)
+		handle(w, request)
+	}))
+	t.Cleanup(server.Close)
+
+	client := meilisearch.NewClient(meilisearch.ClientConfig{Host: server.URL})
+	return client.Index("raindrops"), &requests
+}
+
+// writeHits answers a search with hits numbered from the request offset
+func writeHits(w http.ResponseWriter, offset int64, count int64, total int64) {
+	hits := make([]map[string]interface{}, 0, count)
+	for i := int64(0); i < count; i++ {
+		hits = append(hits, map[string]interface{}{"_id": offset + i + 1, "title": fmt.Sprintf("hit %d", offset+i+1)})
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"hits": hits, "estimatedTotalHits": total})
+}
+
+func TestCountSendsFilter(t *testing.T) {
+	index, requests := newSearchServer(t, func(w http.ResponseWriter, request searchRequest) {
+		writeHits(w, 0, 1, 42)
+	})
+
+	total, err := Count(index, "go", Query{Tags: []string{"go"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 42 {
+		t.Errorf("Count = %d, want 42", total)
+	}
+	if filter := (*requests)[0].Filter; filter != `tags = "go"` {
+		t.Errorf("filter = %q, want the tag filter", filter)
+	}
+	if limit := (*requests)[0].Limit; limit != 1 {
+		t.Errorf("limit = %d, want 1 since only the total is needed", limit)
+	}
+}
+
+func TestSearchAllPages(t *testing.T) {
+	const total = 250
+	index, requests := newSearchServer(t, func(w http.ResponseWriter, request searchRequest) {
+		count := request.Limit
+		if remaining := total - request.Offset; remaining < count {
+			count = remaining
+		}
+		writeHits(w, request.Offset, count, total)
+	})
+
+	result, err := Search(index, "", Query{Limit: 0, Offset: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Hits) != total-20 {
+		t.Errorf("got %d hits, want %d", len(result.Hits), total-20)
+	}
+	if result.Hits[0].ID != 21 {
+		t.Errorf("first hit is %d, want 21 after the offset", result.Hits[0].ID)
+	}
+	if len(*requests) != 3 {
+		t.Errorf("made %d requests, want 3 pages of %d", len(*requests), searchPageSize)
+	}
+}
+
+func TestSearchAllPagesStopsAtMaxResults(t *testing.T) {
+	index, _ := newSearchServer(t, func(w http.ResponseWriter, request searchRequest) {
+		writeHits(w, request.Offset, request.Limit, 1000)
+	})
+
+	result, err := Search(index, "", Query{Limit: 0, MaxResults: 150})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Hits) != 150 {
+		t.Errorf("got %d hits, want MaxResults of 150", len(result.Hits))
+	}
+}
+
+func TestSearchFallsBackWithoutTiebreak(t *testing.T) {
+	index, requests := newSearchServer(t, func(w http.ResponseWriter, request searchRequest) {
+		if len(request.Sort) > 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"message": "created_unix is not sortable", "code": "invalid_search_sort", "type": "invalid_request"}`)
+			return
+		}
+		writeHits(w, request.Offset, 1, 1)
+	})
+
+	result, err := Search(index, "go", Query{Limit: 10, Tiebreak: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Hits) != 1 || len(*requests) != 2 {
+		t.Fatalf("got %d hits in %d requests, want 1 hit after retrying once", len(result.Hits), len(*requests))
+	}
+	if sort := (*requests)[0].Sort; fmt.Sprint(sort) != "[created_unix:desc]" {
+		t.Errorf("first request sorted by %v, want the tiebreak", sort)
+	}
+}
+
+func TestSearchKeepsExplicitSortErrors(t *testing.T) {
+	index, requests := newSearchServer(t, func(w http.ResponseWriter, request searchRequest) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"message": "created_unix is not sortable", "code": "invalid_search_sort", "type": "invalid_request"}`)
+	})
+
+	_, err := Search(index, "go", Query{Limit: 10, Sort: []string{"created_unix:asc"}, Tiebreak: true})
+	if !isInvalidSort(err) {
+		t.Errorf("got %v, want the invalid sort error for an explicit sort", err)
+	}
+	if len(*requests) != 1 {
+		t.Errorf("made %d requests, want no retry", len(*requests))
+	}
+}
+
+func TestWithMinScore(t *testing.T) {
+	hits := []Hit{{RankingScore: 0.9}, {RankingScore: 0.4}, {RankingScore: 0.5}}
+	if got := withMinScore(hits, 0.5); len(got) != 2 {
+		t.Errorf("kept %d hits, want the 2 scoring at least 0.5", len(got))
+	}
+}
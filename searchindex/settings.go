@@ -0,0 +1,118 @@
+package searchindex
+
+import (
+	"fmt"
+	"github.com/meilisearch/meilisearch-go"
+)
+
+// DefaultSearchableAttributes are the fields worth ranking on, leaving out
+// ids and cache metadata
+var DefaultSearchableAttributes = []string{"title", "excerpt", "note", "highlight_text", "tags", "domain", "content"}
+
+// DefaultDisplayedAttributes are the fields returned in search results. The
+// raindrop fields that are only passed through, like broken and lastUpdate,
+// are returned too so they don't come back as zero values. The indexed page
+// content is left out as it can be huge
+var DefaultDisplayedAttributes = []string{"_id", "title", "link", "excerpt", "note", "highlight_text", "domain", "type", "created", "lastUpdate", "tags", "important", "broken", "collection", "collection_id", "collection_title", "collection_path", "cache", "cover", "favicon", "media", "highlights", "file", "user", "creatorRef"}
+
+// FilterableAttributes are the fields search filters can be applied to
+var FilterableAttributes = []string{"tags", "collection_id", "created_unix", "domain", "type", "cache.status", "important"}
+
+// SortableAttributes are the fields search results can be sorted by
+var SortableAttributes = []string{"created_unix"}
+
+// DefaultMinWordSizeOneTypo and DefaultMinWordSizeTwoTypos are meilisearch's
+// own word lengths for allowing one and two typos
+const (
+	DefaultMinWordSizeOneTypo  = 5
+	DefaultMinWordSizeTwoTypos = 9
+)
+
+// Settings are the meilisearch settings that control search quality
+type Settings struct {
+	// Searchable is the list of fields meilisearch ranks on, empty leaves the
+	// index setting unchanged
+	Searchable []string
+	// Displayed is the list of fields meilisearch returns in results, empty
+	// leaves the index setting unchanged
+	Displayed []string
+	// MinWordSizeOneTypo is the shortest word matched with one typo
+	MinWordSizeOneTypo int64
+	// MinWordSizeTwoTypos is the shortest word matched with two typos
+	MinWordSizeTwoTypos int64
+	// Synonyms maps a term to the terms that should match it, nil leaves the
+	// index synonyms unchanged
+	Synonyms map[string][]string
+	// StopWords are ignored in queries and documents, nil leaves the index
+	// stop words unchanged
+	StopWords []string
+}
+
+// Validate checks the settings against meilisearch's limits so bad values
+// fail before any bookmarks are fetched
+func (s Settings) Validate() error {
+	if s.MinWordSizeOneTypo < 0 || s.MinWordSizeTwoTypos > 255 || s.MinWordSizeOneTypo > s.MinWordSizeTwoTypos {
+		return fmt.Errorf("typo word sizes must satisfy 0 <= one typo (%d) <= two typos (%d) <= 255", s.MinWordSizeOneTypo, s.MinWordSizeTwoTypos)
+	}
+	return nil
+}
+
+// typoTolerance returns the typo tolerance setting sent to meilisearch
+func (s Settings) typoTolerance() *meilisearch.TypoTolerance {
+	return &meilisearch.TypoTolerance{
+		Enabled: true,
+		MinWordSizeForTypos: meilisearch.MinWordSizeForTypos{
+			OneTypo:  s.MinWordSizeOneTypo,
+			TwoTypos: s.MinWordSizeTwoTypos,
+		},
+	}
+}
+
+// Configure applies the settings to the index, along with the filterable and
+// sortable attributes searches rely on
+func Configure(index *meilisearch.Index, settings Settings) error {
+	if len(settings.Searchable) > 0 {
+		_, err := index.UpdateSearchableAttributes(&settings.Searchable)
+		if err != nil {
+			return fmt.Errorf("error updating searchable attributes: %w", err)
+		}
+	}
+
+	_, err := index.UpdateFilterableAttributes(&FilterableAttributes)
+	if err != nil {
+		return fmt.Errorf("error updating filterable attributes: %w", err)
+	}
+
+	_, err = index.UpdateSortableAttributes(&SortableAttributes)
+	if err != nil {
+		return fmt.Errorf("error updating sortable attributes: %w", err)
+	}
+
+	if len(settings.Displayed) > 0 {
+		_, err := index.UpdateDisplayedAttributes(&settings.Displayed)
+		if err != nil {
+			return fmt.Errorf("error updating displayed attributes: %w", err)
+		}
+	}
+
+	_, err = index.UpdateTypoTolerance(settings.typoTolerance())
+	if err != nil {
+		return fmt.Errorf("error updating typo tolerance: %w", err)
+	}
+
+	if settings.Synonyms != nil {
+		_, err = index.UpdateSynonyms(&settings.Synonyms)
+		if err != nil {
+			return fmt.Errorf("error updating synonyms: %w", err)
+		}
+	}
+
+	if settings.StopWords != nil {
+		_, err = index.UpdateStopWords(&settings.StopWords)
+		if err != nil {
+			return fmt.Errorf("error updating stop words: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package searchindex
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// settingsRoutes accepts every settings update Configure can send to the
+// raindrops index
+func settingsRoutes() map[string]http.HandlerFunc {
+	routes := map[string]http.HandlerFunc{
+		"PATCH /indexes/raindrops/settings/typo-tolerance": enqueued(1),
+	}
+	for _, setting := range []string{"searchable-attributes", "displayed-attributes", "filterable-attributes", "sortable-attributes", "synonyms", "stop-words"} {
+		routes["PUT /indexes/raindrops/settings/"+setting] = enqueued(1)
+	}
+	return routes
+}
+
+// settingBody returns the body sent to the setting's endpoint, or "" if the
+// setting wasn't updated
+func settingBody(requests []fakeRequest, setting string) string {
+	for _, request := range requests {
+		if strings.HasSuffix(request.Path, "/settings/"+setting) {
+			return request.Body
+		}
+	}
+	return ""
+}
+
+func TestSettingsValidate(t *testing.T) {
+	tests := []struct {
+		oneTypo  int64
+		twoTypos int64
+		valid    bool
+	}{
+		{DefaultMinWordSizeOneTypo, DefaultMinWordSizeTwoTypos, true},
+		{0, 0, true},
+		{4, 4, true},
+		{0, 255, true},
+		{-1, 9, false},
+		{5, 256, false},
+		{9, 5, false},
+	}
+	for _, test := range tests {
+		err := Settings{MinWordSizeOneTypo: test.oneTypo, MinWordSizeTwoTypos: test.twoTypos}.Validate()
+		if (err == nil) != test.valid {
+			t.Errorf("Validate() with %d and %d = %v, want valid %v", test.oneTypo, test.twoTypos, err, test.valid)
+		}
+	}
+}
+
+func TestConfigureSendsTypoTolerance(t *testing.T) {
+	client, requests := newFakeMeilisearch(t, settingsRoutes())
+
+	err := Configure(client.Index("raindrops"), Settings{MinWordSizeOneTypo: 4, MinWordSizeTwoTypos: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := settingBody(*requests, "typo-tolerance")
+	if !strings.Contains(body, `"enabled":true`) || !strings.Contains(body, `"minWordSizeForTypos":{"oneTypo":4,"twoTypos":8}`) {
+		t.Errorf("typo tolerance request = %s, want the configured word sizes", body)
+	}
+	if body := settingBody(*requests, "filterable-attributes"); !strings.Contains(body, `"tags"`) {
+		t.Errorf("filterable attributes request = %q, want FilterableAttributes", body)
+	}
+	for _, setting := range []string{"searchable-attributes", "displayed-attributes", "synonyms", "stop-words"} {
+		if body := settingBody(*requests, setting); body != "" {
+			t.Errorf("unset %s were sent as %s, want them left alone", setting, body)
+		}
+	}
+}
+
+func TestConfigureSendsSynonyms(t *testing.T) {
+	client, requests := newFakeMeilisearch(t, settingsRoutes())
+
+	synonyms := map[string][]string{"k8s": {"kubernetes"}, "kubernetes": {"k8s"}}
+	err := Configure(client.Index("raindrops"), Settings{Synonyms: synonyms})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := settingBody(*requests, "synonyms"); body != `{"k8s":["kubernetes"],"kubernetes":["k8s"]}` {
+		t.Errorf("synonyms request = %s, want the synonyms map", body)
+	}
+}
+
+func TestConfigureSendsStopWords(t *testing.T) {
+	client, requests := newFakeMeilisearch(t, settingsRoutes())
+
+	err := Configure(client.Index("raindrops"), Settings{StopWords: []string{"the", "and"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := settingBody(*requests, "stop-words"); body != `["the","and"]` {
+		t.Errorf("stop words request = %s, want the stop words", body)
+	}
+}
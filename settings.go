@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// readSynonyms reads a JSON object mapping each term to a list of synonyms,
+// like {"k8s": ["kubernetes"], "kubernetes": ["k8s"]}
+func readSynonyms(path string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading synonyms: %w", err)
+	}
+
+	return parseSynonyms(data)
+}
+
+// parseSynonyms decodes and checks a synonyms object, rejecting empty terms
+// so mistakes in the file are reported instead of silently indexed
+func parseSynonyms(data []byte) (map[string][]string, error) {
+	var synonyms map[string][]string
+	err := json.Unmarshal(data, &synonyms)
+	if err != nil {
+		return nil, fmt.Errorf("synonyms must be a JSON object of term to list of synonyms: %w", err)
+	}
+	if synonyms == nil {
+		synonyms = map[string][]string{}
+	}
+
+	for term, list := range synonyms {
+		if strings.TrimSpace(term) == "" {
+			return nil, errors.New("synonyms can't have an empty term")
+		}
+		if len(list) == 0 {
+			return nil, fmt.Errorf("synonyms for %q are empty", term)
+		}
+		for _, synonym := range list {
+			if strings.TrimSpace(synonym) == "" {
+				return nil, fmt.Errorf("synonyms for %q include an empty term", term)
+			}
+		}
+	}
+
+	return synonyms, nil
+}
+
+// defaultStopWords is a short list of common English words used by
+// -stop-words=default
+var defaultStopWords = []string{
+	"a", "an", "and", "are", "as", "at", "be", "by", "for", "from", "how", "in",
+	"is", "it", "of", "on", "or", "that", "the", "this", "to", "was", "what",
+	"with",
+}
+
+// readStopWords reads one stop word per line, skipping blank lines and lines
+// starting with #. The path "default" selects defaultStopWords
+func readStopWords(path string) ([]string, error) {
+	if path == "default" {
+		return defaultStopWords, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening stop words: %w", err)
+	}
+	defer f.Close()
+
+	stopWords := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		stopWords = append(stopWords, strings.ToLower(word))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading stop words: %w", err)
+	}
+
+	return stopWords, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseSynonyms(t *testing.T) {
+	synonyms, err := parseSynonyms([]byte(`{"k8s": ["kubernetes"], "kubernetes": ["k8s", "kube"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(synonyms) != "map[k8s:[kubernetes] kubernetes:[k8s kube]]" {
+		t.Errorf("parseSynonyms() = %v, want every term with its synonyms", synonyms)
+	}
+
+	tests := []struct {
+		data string
+		want string
+	}{
+		{`["k8s", "kubernetes"]`, "must be a JSON object"},
+		{`{"k8s": "kubernetes"}`, "must be a JSON object"},
+		{`{" ": ["kubernetes"]}`, "empty term"},
+		{`{"k8s": []}`, `synonyms for "k8s" are empty`},
+		{`{"k8s": ["kubernetes", ""]}`, `synonyms for "k8s" include an empty term`},
+	}
+	for _, test := range tests {
+		_, err := parseSynonyms([]byte(test.data))
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("parseSynonyms(%s) = %v, want an error containing %q", test.data, err, test.want)
+		}
+	}
+}
+
+func TestReadSynonyms(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "synonyms.json")
+	err := os.WriteFile(path, []byte(`null`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	synonyms, err := readSynonyms(path)
+	if err != nil || synonyms == nil || len(synonyms) != 0 {
+		t.Errorf("readSynonyms() of null = %v, %v, want an empty map that clears the index synonyms", synonyms, err)
+	}
+
+	_, err = readSynonyms(filepath.Join(t.TempDir(), "missing.json"))
+	if err == nil || !strings.Contains(err.Error(), "error reading synonyms") {
+		t.Errorf("readSynonyms() of a missing file = %v, want a read error", err)
+	}
+}
+
+func TestReadStopWords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stop-words.txt")
+	err := os.WriteFile(path, []byte("# common words\nThe\n\n  and \nof\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stopWords, err := readStopWords(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(stopWords) != "[the and of]" {
+		t.Errorf("readStopWords() = %v, want the lowercased words without comments or blank lines", stopWords)
+	}
+
+	stopWords, err = readStopWords("default")
+	if err != nil || len(stopWords) != len(defaultStopWords) {
+		t.Errorf("readStopWords(default) = %v, %v, want the built-in list", stopWords, err)
+	}
+
+	_, err = readStopWords(filepath.Join(t.TempDir(), "missing.txt"))
+	if err == nil || !strings.Contains(err.Error(), "error opening stop words") {
+		t.Errorf("readStopWords() of a missing file = %v, want an open error", err)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/zpeters/dropsearch/raindropio"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// indexState is persisted between runs so incremental indexing knows which
+// bookmarks have already been sent to meilisearch
+type indexState struct {
+	LastUpdate time.Time `json:"lastUpdate"`
+	// LastRun describes the most recent index run, nil before the first
+	LastRun *runRecord `json:"lastRun,omitempty"`
+}
+
+// runRecord describes how an index run went, for -last-run
+type runRecord struct {
+	Started   time.Time `json:"started"`
+	Duration  string    `json:"duration"`
+	Documents int       `json:"documents"`
+	// Error is why the run failed, empty if it succeeded
+	Error string `json:"error,omitempty"`
+}
+
+// newRunRecord describes a run between started and finished that sent
+// documents to meilisearch and ended with err
+func newRunRecord(started time.Time, finished time.Time, documents int, err error) runRecord {
+	record := runRecord{
+		Started:   started,
+		Duration:  finished.Sub(started).Round(time.Millisecond).String(),
+		Documents: documents,
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	return record
+}
+
+// configDir returns the dropsearch directory under the user config dir
+func configDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("error finding config dir: %w", err)
+	}
+	return filepath.Join(dir, "dropsearch"), nil
+}
+
+// indexID identifies an index by its name and the meilisearch host it is on,
+// profiles can use the same index name on different hosts
+type indexID struct {
+	Host  string
+	Index string
+}
+
+// fileKey names the files kept for the index, the index name followed by a
+// hash of the host
+func (id indexID) fileKey() string {
+	h := fnv.New32a()
+	h.Write([]byte(id.Host))
+	return fmt.Sprintf("%s-%08x", id.Index, h.Sum32())
+}
+
+// stateFilePath returns the state file of an index, each index keeps its own
+// state since they can hold different accounts
+func stateFilePath(id indexID) (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "state-"+id.fileKey()+".json"), nil
+}
+
+// loadIndexState reads the saved state, returning an empty state if nothing
+// has been indexed yet
+func loadIndexState(id indexID) (indexState, error) {
+	var state indexState
+
+	path, err := stateFilePath(id)
+	if err != nil {
+		return state, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("error reading state file: %w", err)
+	}
+
+	err = json.Unmarshal(data, &state)
+	if err != nil {
+		return state, fmt.Errorf("error unmarshalling state file: %w", err)
+	}
+
+	return state, nil
+}
+
+func saveIndexState(id indexID, state indexState) error {
+	path, err := stateFilePath(id)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return fmt.Errorf("error creating config dir: %w", err)
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling state: %w", err)
+	}
+
+	err = os.WriteFile(path, data, 0o644)
+	if err != nil {
+		return fmt.Errorf("error writing state file: %w", err)
+	}
+
+	return nil
+}
+
+// saveLastRun stores the record of a run in the index state, leaving the
+// rest of the state alone
+func saveLastRun(id indexID, record runRecord) error {
+	state, err := loadIndexState(id)
+	if err != nil {
+		return err
+	}
+	state.LastRun = &record
+	return saveIndexState(id, state)
+}
+
+// printLastRun writes when the index was last updated, how many documents
+// were sent and whether the run succeeded
+func printLastRun(w io.Writer, id indexID) error {
+	state, err := loadIndexState(id)
+	if err != nil {
+		return err
+	}
+	if state.LastRun == nil {
+		fmt.Fprintf(w, "index %q hasn't been indexed yet\n", id.Index)
+		return nil
+	}
+
+	run := state.LastRun
+	result := "succeeded"
+	if run.Error != "" {
+		result = "failed: " + run.Error
+	}
+	fmt.Fprintf(w, "last run of %q started %s, took %s and sent %d documents, %s\n", id.Index, run.Started.Local().Format("2006-01-02 15:04:05"), run.Duration, run.Documents, result)
+	return nil
+}
+
+// forgetLastUpdate clears the incremental state of an index so the next run
+// indexes every bookmark, the last run record is kept
+func forgetLastUpdate(id indexID) error {
+	state, err := loadIndexState(id)
+	if err != nil {
+		return err
+	}
+	state.LastUpdate = time.Time{}
+	return saveIndexState(id, state)
+}
+
+// changedSince returns the raindrops updated after since
+func changedSince(raindrops []raindropio.Raindrop, since time.Time) []raindropio.Raindrop {
+	var changed []raindropio.Raindrop
+	for _, raindrop := range raindrops {
+		if raindrop.LastUpdate.After(since) {
+			changed = append(changed, raindrop)
+		}
+	}
+	return changed
+}
+
+// latestUpdate returns the most recent lastUpdate of the raindrops
+func latestUpdate(raindrops []raindropio.Raindrop) time.Time {
+	var latest time.Time
+	for _, raindrop := range raindrops {
+		if raindrop.LastUpdate.After(latest) {
+			latest = raindrop.LastUpdate
+		}
+	}
+	return latest
+}
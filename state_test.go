@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/zpeters/dropsearch/raindropio"
+	"testing"
+	"time"
+)
+
+func TestChangedSince(t *testing.T) {
+	since := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	raindrops := []raindropio.Raindrop{
+		{ID: 1, LastUpdate: since.Add(-time.Hour)},
+		{ID: 2, LastUpdate: since},
+		{ID: 3, LastUpdate: since.Add(time.Second)},
+	}
+
+	var ids []int
+	for _, raindrop := range changedSince(raindrops, since) {
+		ids = append(ids, raindrop.ID)
+	}
+	if fmt.Sprint(ids) != "[3]" {
+		t.Errorf("changed %v, want only [3] updated after the last run", ids)
+	}
+	if got := changedSince(raindrops, time.Time{}); len(got) != 3 {
+		t.Errorf("kept %d without a last run, want all 3", len(got))
+	}
+
+	if latest := latestUpdate(raindrops); !latest.Equal(since.Add(time.Second)) {
+		t.Errorf("latestUpdate = %s, want the newest lastUpdate", latest)
+	}
+	if latest := latestUpdate(nil); !latest.IsZero() {
+		t.Errorf("latestUpdate of nothing = %s, want the zero time", latest)
+	}
+}
+
+func TestIndexIDKeepsHostsApart(t *testing.T) {
+	isolate(t)
+	home := indexID{Host: "http://home:7700", Index: "raindrops"}
+	work := indexID{Host: "http://work:7700", Index: "raindrops"}
+
+	homePath, _ := stateFilePath(home)
+	workPath, _ := stateFilePath(work)
+	if homePath == workPath {
+		t.Errorf("both hosts use %s, want a state file each", homePath)
+	}
+	homeCache, _ := collectionCachePath(home)
+	workCache, _ := collectionCachePath(work)
+	if homeCache == workCache {
+		t.Errorf("both hosts use %s, want a collections cache each", homeCache)
+	}
+
+	since := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	err := saveIndexState(home, indexState{LastUpdate: since})
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := loadIndexState(work)
+	if err != nil || !state.LastUpdate.IsZero() {
+		t.Errorf("work loaded %+v, %v, want none of home's state", state, err)
+	}
+	state, err = loadIndexState(home)
+	if err != nil || !state.LastUpdate.Equal(since) {
+		t.Errorf("home loaded %+v, %v, want its saved state back", state, err)
+	}
+}
+
+func TestLastRunRoundTrip(t *testing.T) {
+	isolate(t)
+	id := indexID{Host: "http://search", Index: "raindrops"}
+
+	var b bytes.Buffer
+	err := printLastRun(&b, id)
+	if err != nil || b.String() != "index \"raindrops\" hasn't been indexed yet\n" {
+		t.Errorf("before any run printed %q, %v", b.String(), err)
+	}
+
+	since := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	err = saveIndexState(id, indexState{LastUpdate: since})
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := time.Date(2024, 5, 2, 8, 30, 0, 0, time.Local)
+	err = saveLastRun(id, newRunRecord(started, started.Add(1500*time.Millisecond), 42, errors.New("meilisearch is down")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b.Reset()
+	err = printLastRun(&b, id)
+	want := "last run of \"raindrops\" started 2024-05-02 08:30:00, took 1.5s and sent 42 documents, failed: meilisearch is down\n"
+	if err != nil || b.String() != want {
+		t.Errorf("printed %q, %v, want %q", b.String(), err, want)
+	}
+	state, err := loadIndexState(id)
+	if err != nil || !state.LastUpdate.Equal(since) {
+		t.Errorf("saving the run left lastUpdate %v, %v, want it kept", state.LastUpdate, err)
+	}
+}